@@ -0,0 +1,325 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAdminListLimit is the number of keys listed when the request does not set a limit.
+const defaultAdminListLimit = 1000
+
+// Admin is an http.Handler to inspect and purge the entries of a Storer,
+// meant to be mounted with http.StripPrefix. The records that keyers keep in the storer are not entries,
+// they are left out and never deleted. It serves:
+//
+//	GET    /keys?prefix=&after=&limit=  a page of the keys of the entries, if the storer is a Lister
+//	GET    /entry?key=                  the status, headers, size and metadata of an entry
+//	DELETE /entry?key=                  deletes an entry
+//	POST   /purge?prefix=               deletes every entry whose key starts with the prefix, if the storer is a Lister
+//	GET    /stats                       the number and the size of the entries, if the storer is a Lister
+type Admin struct {
+	storer   Storer
+	auth     func(r *http.Request) bool
+	observer Observer
+}
+
+type AdminOption func(a *Admin)
+
+// WithAdminObserver sets the Observer notified of the entries deleted by the Admin as EventEviction.
+func WithAdminObserver(observer Observer) AdminOption {
+	return func(a *Admin) {
+		a.observer = observer
+	}
+}
+
+// NewAdmin returns the Admin of the storer, requests are only served if auth allows them,
+// a nil auth denies every request.
+func NewAdmin(storer Storer, auth func(r *http.Request) bool, options ...AdminOption) http.Handler {
+	a := &Admin{
+		storer: storer,
+		auth:   auth,
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+func (a *Admin) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if a.auth == nil || !a.auth(r) {
+		adminError(rw, http.StatusForbidden, "forbidden")
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "keys":
+		if r.Method != http.MethodGet {
+			adminMethodNotAllowed(rw, http.MethodGet)
+			return
+		}
+		a.keys(rw, r)
+	case "entry":
+		switch r.Method {
+		case http.MethodGet:
+			a.entry(rw, r)
+		case http.MethodDelete:
+			a.delete(rw, r)
+		default:
+			adminMethodNotAllowed(rw, http.MethodGet, http.MethodDelete)
+		}
+	case "purge":
+		if r.Method != http.MethodPost {
+			adminMethodNotAllowed(rw, http.MethodPost)
+			return
+		}
+		a.purge(rw, r)
+	case "stats":
+		if r.Method != http.MethodGet {
+			adminMethodNotAllowed(rw, http.MethodGet)
+			return
+		}
+		a.stats(rw, r)
+	default:
+		adminError(rw, http.StatusNotFound, "not found")
+	}
+}
+
+type adminKey struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size,omitempty"`
+}
+
+type adminKeys struct {
+	Keys []adminKey `json:"keys"`
+	// Next is the after of the next page, empty on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+func (a *Admin) keys(rw http.ResponseWriter, r *http.Request) {
+	lister, ok := a.storer.(Lister)
+	if !ok {
+		adminError(rw, http.StatusNotImplemented, "storer cannot list its entries")
+		return
+	}
+	query := r.URL.Query()
+	limit := defaultAdminListLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			adminError(rw, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	list := adminKeys{Keys: []adminKey{}}
+	prefix, after := query.Get("prefix"), query.Get("after")
+	// One more key than the limit tells whether there is a next page,
+	// pages are listed until they have it as records are left out.
+	for {
+		want := limit + 1 - len(list.Keys)
+		n := 0
+		err := lister.List(prefix, after, want, func(key string, size int64) error {
+			n++
+			after = key
+			if !isRecordKey(key) {
+				list.Keys = append(list.Keys, adminKey{Key: key, Size: size})
+			}
+			return nil
+		})
+		if err != nil {
+			adminError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if n < want || len(list.Keys) > limit {
+			break
+		}
+	}
+	if len(list.Keys) > limit {
+		list.Keys = list.Keys[:limit]
+		list.Next = list.Keys[limit-1].Key
+	}
+	adminJSON(rw, http.StatusOK, list)
+}
+
+type adminEntry struct {
+	Key       string      `json:"key"`
+	Status    string      `json:"status"`
+	Header    http.Header `json:"header"`
+	Size      int64       `json:"size"`
+	Stored    time.Time   `json:"stored"`
+	Age       int64       `json:"age"`
+	TTL       *int64      `json:"ttl,omitempty"`
+	Fresh     bool        `json:"fresh"`
+	Heuristic bool        `json:"heuristic,omitempty"`
+
+	// Modified and Metadata are reported if the storer is a Stater.
+	Modified *time.Time        `json:"modified,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (a *Admin) entry(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if isRecordKey(key) {
+		adminError(rw, http.StatusNotFound, "entry not found")
+		return
+	}
+	data, ok := a.storer.Get(key)
+	if !ok {
+		adminError(rw, http.StatusNotFound, "entry not found")
+		return
+	}
+	defer data.Close()
+	resp, err := unmarshalResponse(data)
+	if err != nil {
+		adminError(rw, http.StatusUnprocessableEntity, "corrupt entry: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	size, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		adminError(rw, http.StatusUnprocessableEntity, "corrupt entry: "+err.Error())
+		return
+	}
+	meta := popEntryMeta(resp.Header)
+	now := time.Now()
+	entry := adminEntry{
+		Key:       key,
+		Status:    resp.Status,
+		Header:    resp.Header,
+		Size:      size,
+		Stored:    meta.stored.UTC(),
+		Age:       int64(meta.currentAge(now) / time.Second),
+		Fresh:     meta.fresh(now),
+		Heuristic: meta.heuristic,
+	}
+	if meta.expires {
+		ttl := int64(meta.ttl / time.Second)
+		entry.TTL = &ttl
+	}
+	if stater, ok := a.storer.(Stater); ok {
+		if info, ok := stater.Stat(key); ok {
+			modified := info.ModTime.UTC()
+			entry.Modified = &modified
+			entry.Metadata = info.Metadata
+		}
+	}
+	adminJSON(rw, http.StatusOK, entry)
+}
+
+type adminDeleted struct {
+	Deleted int `json:"deleted"`
+}
+
+func (a *Admin) delete(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if isRecordKey(key) || !a.storer.Del(key) {
+		adminError(rw, http.StatusNotFound, "entry not found")
+		return
+	}
+	a.evicted(key)
+	adminJSON(rw, http.StatusOK, adminDeleted{Deleted: 1})
+}
+
+func (a *Admin) purge(rw http.ResponseWriter, r *http.Request) {
+	lister, ok := a.storer.(Lister)
+	if !ok {
+		adminError(rw, http.StatusNotImplemented, "storer cannot enumerate its entries")
+		return
+	}
+	query := r.URL.Query()
+	if !query.Has("prefix") {
+		adminError(rw, http.StatusBadRequest, "missing prefix")
+		return
+	}
+	deleted, err := purgePrefix(lister, a.storer, query.Get("prefix"), nil)
+	if err != nil {
+		adminError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.evicted(deleted...)
+	adminJSON(rw, http.StatusOK, adminDeleted{Deleted: len(deleted)})
+}
+
+func (a *Admin) evicted(keys ...string) {
+	if a.observer == nil {
+		return
+	}
+	for _, key := range keys {
+		a.observer.Observe(Event{Kind: EventEviction, Key: key})
+	}
+}
+
+// purgePrefix deletes every entry whose key starts with the prefix and matches re if it is not nil,
+// keeping the records of the keyers, and returns the keys of the deleted entries.
+// The keys are collected first, as storers are not required to support deleting while listing.
+func purgePrefix(lister Lister, storer Storer, prefix string, re *regexp.Regexp) ([]string, error) {
+	var keys []string
+	err := lister.List(prefix, "", 0, func(key string, size int64) error {
+		if !isRecordKey(key) && (re == nil || re.MatchString(key)) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleted := keys[:0]
+	for _, key := range keys {
+		if storer.Del(key) {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted, nil
+}
+
+type adminStats struct {
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+func (a *Admin) stats(rw http.ResponseWriter, r *http.Request) {
+	lister, ok := a.storer.(Lister)
+	if !ok {
+		adminError(rw, http.StatusNotImplemented, "storer cannot enumerate its entries")
+		return
+	}
+	var stats adminStats
+	err := lister.List("", "", 0, func(key string, size int64) error {
+		if isRecordKey(key) {
+			return nil
+		}
+		stats.Entries++
+		stats.Bytes += size
+		return nil
+	})
+	if err != nil {
+		adminError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	adminJSON(rw, http.StatusOK, stats)
+}
+
+func adminMethodNotAllowed(rw http.ResponseWriter, methods ...string) {
+	for _, method := range methods {
+		rw.Header().Add("Allow", method)
+	}
+	adminError(rw, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+type adminErrorBody struct {
+	Error string `json:"error"`
+}
+
+func adminError(rw http.ResponseWriter, code int, msg string) {
+	adminJSON(rw, code, adminErrorBody{Error: msg})
+}
+
+func adminJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(v)
+}
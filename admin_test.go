@@ -0,0 +1,147 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	storer := MemoryStorer()
+	handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60"), WithStorer(storer))
+	for _, path := range []string{"/a/1", "/a/2", "/b"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+	}
+	keyA1 := JointKeyer(HostKeyer(), PathKeyer()).Key(httptest.NewRequest(http.MethodGet, "http://example.com/a/1", nil))
+
+	admin := http.StripPrefix("/admin", NewAdmin(storer, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	}))
+	do := func(method, target string, v interface{}) int {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rw := httptest.NewRecorder()
+		admin.ServeHTTP(rw, req)
+		if v != nil {
+			if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s %s: %s: %s", method, target, err, rw.Body.String())
+			}
+		}
+		return rw.Code
+	}
+
+	rw := httptest.NewRecorder()
+	admin.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("want %d, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	var stats adminStats
+	if code := do(http.MethodGet, "/admin/stats", &stats); code != http.StatusOK || stats.Entries != 3 || stats.Bytes == 0 {
+		t.Errorf("unexpected stats %d %+v", code, stats)
+	}
+
+	var list adminKeys
+	if code := do(http.MethodGet, "/admin/keys?limit=2", &list); code != http.StatusOK || len(list.Keys) != 2 || list.Next != list.Keys[1].Key || list.Keys[0].Size == 0 {
+		t.Errorf("unexpected keys %d %+v", code, list)
+	}
+	next := list.Next
+	list = adminKeys{}
+	if code := do(http.MethodGet, "/admin/keys?limit=2&after="+url.QueryEscape(next), &list); code != http.StatusOK || len(list.Keys) != 1 || list.Next != "" {
+		t.Errorf("unexpected keys %d %+v", code, list)
+	}
+
+	var entry adminEntry
+	if code := do(http.MethodGet, "/admin/entry?key="+url.QueryEscape(keyA1), &entry); code != http.StatusOK {
+		t.Fatalf("unexpected code %d", code)
+	}
+	if entry.Status != "200 OK" || entry.Size != 1 || entry.TTL == nil || *entry.TTL != 60 || !entry.Fresh {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry.Modified == nil {
+		t.Errorf("missing modification time %+v", entry)
+	}
+	if entry.Header.Get("Cache-Control") != "max-age=60" || entry.Header.Get(headerStored) != "" {
+		t.Errorf("unexpected header %v", entry.Header)
+	}
+
+	var deleted adminDeleted
+	if code := do(http.MethodDelete, "/admin/entry?key="+url.QueryEscape(keyA1), &deleted); code != http.StatusOK || deleted.Deleted != 1 {
+		t.Errorf("unexpected delete %d %+v", code, deleted)
+	}
+	if code := do(http.MethodDelete, "/admin/entry?key="+url.QueryEscape(keyA1), nil); code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, code)
+	}
+
+	if code := do(http.MethodPost, "/admin/purge", nil); code != http.StatusBadRequest {
+		t.Errorf("want %d, got %d", http.StatusBadRequest, code)
+	}
+	if code := do(http.MethodPost, "/admin/purge?prefix=example.com/", &deleted); code != http.StatusOK || deleted.Deleted != 2 {
+		t.Errorf("unexpected purge %d %+v", code, deleted)
+	}
+	if code := do(http.MethodGet, "/admin/purge", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestAdminRecords(t *testing.T) {
+	storer := MemoryStorer()
+	keyer := NewGenerationKeyer("site", VaryKeyer(JointKeyer(HostKeyer(), PathKeyer()), storer), storer)
+	keyer.Bump()
+	handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60", "Vary", "Accept"), WithStorer(storer), WithKeyer(keyer))
+	for _, path := range []string{"/a", "/b"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+	}
+
+	admin := NewAdmin(storer, func(r *http.Request) bool { return true })
+	do := func(method, target string, v interface{}) int {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		admin.ServeHTTP(rw, httptest.NewRequest(method, target, nil))
+		if v != nil {
+			if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s %s: %s: %s", method, target, err, rw.Body.String())
+			}
+		}
+		return rw.Code
+	}
+
+	var stats adminStats
+	if code := do(http.MethodGet, "/stats", &stats); code != http.StatusOK || stats.Entries != 2 {
+		t.Errorf("unexpected stats %d %+v", code, stats)
+	}
+
+	var keys []string
+	after := ""
+	for {
+		var list adminKeys
+		if code := do(http.MethodGet, "/keys?limit=1&after="+url.QueryEscape(after), &list); code != http.StatusOK {
+			t.Fatalf("unexpected code %d", code)
+		}
+		for _, key := range list.Keys {
+			keys = append(keys, key.Key)
+		}
+		if list.Next == "" {
+			break
+		}
+		after = list.Next
+	}
+	if len(keys) != 2 {
+		t.Errorf("want the 2 entries, got %q", keys)
+	}
+
+	if code := do(http.MethodGet, "/entry?key="+url.QueryEscape("site/"+generationRecord), nil); code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, code)
+	}
+
+	var deleted adminDeleted
+	if code := do(http.MethodPost, "/purge?prefix=", &deleted); code != http.StatusOK || deleted.Deleted != 2 {
+		t.Errorf("unexpected purge %d %+v", code, deleted)
+	}
+	if got := NewGenerationKeyer("site", PathKeyer(), storer).Generation(); got != 1 {
+		t.Errorf("want the generation to be kept, got %d", got)
+	}
+}
@@ -3,6 +3,7 @@ package httpcache
 import (
 	"io"
 	"net/http"
+	"time"
 )
 
 type Filterer interface {
@@ -30,6 +31,24 @@ func (f DiscarderFunc) Discard(resp Response) bool {
 	return f(resp)
 }
 
+// BodyDiscarder is implemented by discarders that need the response body to decide,
+// the body is buffered and DiscardBody is called in place of Discard.
+type BodyDiscarder interface {
+	Discarder
+	DiscardBody(resp Response, body []byte) bool
+}
+
+// BodyDiscarderFunc is a BodyDiscarder, its Discard inspects an empty body.
+type BodyDiscarderFunc func(resp Response, body []byte) bool
+
+func (f BodyDiscarderFunc) Discard(resp Response) bool {
+	return f(resp, nil)
+}
+
+func (f BodyDiscarderFunc) DiscardBody(resp Response, body []byte) bool {
+	return f(resp, body)
+}
+
 type Keyer interface {
 	Key(req *http.Request) string
 }
@@ -40,8 +59,73 @@ func (k KeyerFunc) Key(req *http.Request) string {
 	return k(req)
 }
 
+// SkipKeyer is implemented by keyers that cannot key every request, such as requests whose body is too large,
+// KeyOrSkip returns false for the requests that must bypass the cache.
+// Key returns an empty key for them, and when called by Handler or RoundTripper still makes them bypass the cache,
+// even through keyers wrapping it that do not implement SkipKeyer.
+type SkipKeyer interface {
+	Keyer
+	KeyOrSkip(req *http.Request) (string, bool)
+}
+
+// SkipKeyerFunc is a SkipKeyer.
+type SkipKeyerFunc func(req *http.Request) (string, bool)
+
+func (k SkipKeyerFunc) Key(req *http.Request) string {
+	key, ok := k(req)
+	if !ok {
+		skipRequest(req)
+		return ""
+	}
+	return key
+}
+
+func (k SkipKeyerFunc) KeyOrSkip(req *http.Request) (string, bool) {
+	return k(req)
+}
+
+// Varier is implemented by keyers whose keys depend on the Vary header of the responses.
+// Before a response is stored, VaryKey learns from it and returns the key to store it under,
+// or false if the response must not be stored. The key is the one Key returned for the request,
+// whose body may have been consumed since.
+type Varier interface {
+	Keyer
+	VaryKey(req *http.Request, key string, resp Response) (string, bool)
+}
+
 type Storer interface {
 	Get(key string) (io.ReadCloser, bool)
 	Put(key string) (io.WriteCloser, bool)
+	// Del removes the entry and reports whether it existed.
 	Del(key string) bool
 }
+
+// Lister is implemented by storers that can enumerate their entries.
+type Lister interface {
+	// List calls fn with the keys starting with the prefix and sorting after the key after,
+	// an empty after starts from the first key, and with the size of their entries.
+	// With a positive limit, fn is called with up to limit keys in lexical order, so that the last key
+	// is the after of the next page, otherwise it is called with every key in no particular order.
+	// It stops at and returns the first error returned by fn.
+	List(prefix, after string, limit int, fn func(key string, size int64) error) error
+}
+
+// Evicter is implemented by storers that remove entries on their own, such as to bound their size.
+// Handler and RoundTripper pass their Observer to ObserveEvictions,
+// the storer then reports every entry it removes on its own as EventEviction.
+type Evicter interface {
+	ObserveEvictions(observer Observer)
+}
+
+// EntryInfo describes an entry of a storer.
+type EntryInfo struct {
+	Size    int64
+	ModTime time.Time
+	// Metadata is specific to the storer, such as the path of the file of the entry.
+	Metadata map[string]string
+}
+
+// Stater is implemented by storers that can describe an entry without reading it.
+type Stater interface {
+	Stat(key string) (EntryInfo, bool)
+}
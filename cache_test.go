@@ -1,8 +1,13 @@
 package httpcache
 
 import (
+	"fmt"
 	"io"
+	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 )
 
 func TestStorer(t *testing.T) {
@@ -19,6 +24,10 @@ func TestStorer(t *testing.T) {
 			name:   "DirectoryStorer",
 			storer: DirectoryStorer("./tmp/"),
 		},
+		{
+			name:   "ShardedDirectoryStorer",
+			storer: ShardedDirectoryStorer("./tmp/sharded/"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -61,3 +70,187 @@ func TestStorer(t *testing.T) {
 		})
 	}
 }
+
+func TestLister(t *testing.T) {
+	tests := []struct {
+		name   string
+		storer Storer
+	}{
+		{
+			name:   "MemoryStorer",
+			storer: MemoryStorer(),
+		},
+		{
+			name:   "DirectoryStorer",
+			storer: DirectoryStorer(t.TempDir()),
+		},
+		{
+			name:   "ShardedDirectoryStorer",
+			storer: ShardedDirectoryStorer(t.TempDir()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := map[string]string{
+				"a/b/1": "one",
+				"a/b/2": "two",
+				"a/c":   "three",
+				"b/1":   "four",
+			}
+			for key, data := range entries {
+				w, ok := tt.storer.Put(key)
+				if !ok {
+					t.Fatalf("expected to get the writer for %q", key)
+				}
+				w.Write([]byte(data))
+				w.Close()
+			}
+			for prefix, want := range map[string][]string{
+				"":       {"a/b/1", "a/b/2", "a/c", "b/1"},
+				"a/":     {"a/b/1", "a/b/2", "a/c"},
+				"a/b/":   {"a/b/1", "a/b/2"},
+				"a/b/1":  {"a/b/1"},
+				"c/":     nil,
+				"a/b/3/": nil,
+			} {
+				var got []string
+				err := tt.storer.(Lister).List(prefix, "", 0, func(key string, size int64) error {
+					if size != int64(len(entries[key])) {
+						t.Errorf("%q: want size %d, got %d", key, len(entries[key]), size)
+					}
+					got = append(got, key)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(got)
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("prefix %q: want %q, got %q", prefix, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestListerStater(t *testing.T) {
+	tests := []struct {
+		name   string
+		storer Storer
+	}{
+		{
+			name:   "MemoryStorer",
+			storer: MemoryStorer(),
+		},
+		{
+			name:   "DirectoryStorer",
+			storer: DirectoryStorer(t.TempDir()),
+		},
+		{
+			name:   "ShardedDirectoryStorer",
+			storer: ShardedDirectoryStorer(t.TempDir()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().Add(-time.Second)
+			for _, key := range []string{"a/b/2", "a/c", "a/b/1", "a-b", "b/1"} {
+				w, ok := tt.storer.Put(key)
+				if !ok {
+					t.Fatalf("expected to get the writer for %q", key)
+				}
+				w.Write([]byte(key))
+				w.Close()
+			}
+
+			lister := tt.storer.(Lister)
+			list := func(prefix, after string, limit int) ([]string, error) {
+				keys := []string{}
+				err := lister.List(prefix, after, limit, func(key string, size int64) error {
+					if size != int64(len(key)) {
+						t.Errorf("%q: want size %d, got %d", key, len(key), size)
+					}
+					keys = append(keys, key)
+					return nil
+				})
+				return keys, err
+			}
+			var pages [][]string
+			after := ""
+			for {
+				keys, err := list("a", after, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(keys) == 0 {
+					break
+				}
+				pages = append(pages, keys)
+				after = keys[len(keys)-1]
+			}
+			want := [][]string{{"a-b", "a/b/1"}, {"a/b/2", "a/c"}}
+			if !reflect.DeepEqual(want, pages) {
+				t.Errorf("want %q, got %q", want, pages)
+			}
+
+			tt.storer.Del("a/b/1")
+			w, _ := tt.storer.Put("a/a")
+			w.Write([]byte("a/a"))
+			w.Close()
+			for _, page := range []struct {
+				prefix string
+				after  string
+				want   []string
+			}{
+				{"a", "", []string{"a-b", "a/a", "a/b/2", "a/c"}},
+				{"a/b/", "", []string{"a/b/2"}},
+				{"", "a/c", []string{"b/1"}},
+				{"", "a/b", []string{"a/b/2", "a/c", "b/1"}},
+				{"c", "", []string{}},
+			} {
+				keys, err := list(page.prefix, page.after, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(page.want, keys) {
+					t.Errorf("prefix %q after %q: want %q, got %q", page.prefix, page.after, page.want, keys)
+				}
+			}
+
+			stater := tt.storer.(Stater)
+			info, ok := stater.Stat("a/b/2")
+			if !ok {
+				t.Fatal("expected to be available")
+			}
+			if info.Size != int64(len("a/b/2")) || info.ModTime.Before(before) {
+				t.Errorf("unexpected %+v", info)
+			}
+			if _, ok := stater.Stat("a/b"); ok {
+				t.Error("expected to be unavailable")
+			}
+		})
+	}
+}
+
+func TestListPage(t *testing.T) {
+	var keys []string
+	for i := 0; i != 1000; i++ {
+		keys = append(keys, fmt.Sprintf("%04d", i))
+	}
+	rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	page := newListPage(7)
+	for _, key := range keys {
+		page.add(key, 0)
+	}
+	var got []string
+	page.each(func(key string, size int64) error {
+		got = append(got, key)
+		return nil
+	})
+	want := []string{"0000", "0001", "0002", "0003", "0004", "0005", "0006"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,39 @@
+package httpcache
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash/fnv"
+)
+
+// Digest turns a key component into a string that is safe to use in keys.
+type Digest func(data []byte) string
+
+// MD5Digest is the hex encoded MD5 of the data.
+func MD5Digest(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SHA256Digest is the hex encoded SHA-256 of the data.
+func SHA256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FNVDigest is the hex encoded 64-bit FNV-1a of the data,
+// a fast non-cryptographic hash for keys that are not controlled by untrusted clients.
+func FNVDigest(data []byte) string {
+	h := fnv.New64a()
+	h.Write(data)
+	var tmp [8]byte
+	return hex.EncodeToString(h.Sum(tmp[:0]))
+}
+
+// Base64Digest does not hash, it encodes the data with base64 so that keys stay readable,
+// their length grows with the data.
+func Base64Digest(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(data)
+}
@@ -1,12 +1,16 @@
 package httpcache
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Directory string
@@ -16,7 +20,10 @@ func DirectoryStorer(dir string) Storer {
 }
 
 func (d Directory) Get(key string) (io.ReadCloser, bool) {
-	path := filepath.Join(string(d), key)
+	path, ok := d.path(key)
+	if !ok {
+		return nil, false
+	}
 	f, err := os.OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, false
@@ -27,7 +34,10 @@ func (d Directory) Get(key string) (io.ReadCloser, bool) {
 }
 
 func (d Directory) Put(key string) (io.WriteCloser, bool) {
-	path := filepath.Join(string(d), key)
+	path, ok := d.path(key)
+	if !ok {
+		return nil, false
+	}
 	os.MkdirAll(filepath.Dir(path), 0755)
 	w, err := writeToCompletion(path, 0644)
 	if err != nil {
@@ -37,9 +47,153 @@ func (d Directory) Put(key string) (io.WriteCloser, bool) {
 }
 
 func (d Directory) Del(key string) bool {
-	path := filepath.Join(string(d), key)
-	os.Remove(path)
-	return true
+	path, ok := d.path(key)
+	if !ok {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// List reads the directories in the order of the keys and stops once it has the page,
+// skipping the directories whose keys all sort before after.
+func (d Directory) List(prefix, after string, limit int, fn func(key string, size int64) error) error {
+	l := directoryLister{
+		prefix: prefix,
+		after:  after,
+		limit:  limit,
+		fn:     fn,
+	}
+	// Only list the deepest directory that can contain the keys.
+	start, base := string(d), ""
+	if i := strings.LastIndexByte(prefix, '/'); i != -1 {
+		dir, ok := d.path(prefix[:i])
+		if !ok {
+			return nil
+		}
+		start, base = dir, prefix[:i+1]
+	}
+	_, err := l.list(start, base)
+	return err
+}
+
+type directoryLister struct {
+	prefix string
+	after  string
+	limit  int
+	fn     func(key string, size int64) error
+	n      int
+}
+
+// list calls fn with the keys below the directory, whose keys start with base,
+// and reports whether the page is full.
+func (l *directoryLister) list(dir, base string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	// The keys below a directory named n start with n/, which does not sort like n,
+	// e.g. the file a-b sorts before the directory a.
+	sortName := func(entry fs.DirEntry) string {
+		if entry.IsDir() {
+			return entry.Name() + "/"
+		}
+		return entry.Name()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+	for _, entry := range entries {
+		key := base + sortName(entry)
+		if entry.IsDir() {
+			if !strings.HasPrefix(key, l.prefix) && !strings.HasPrefix(l.prefix, key) {
+				continue
+			}
+			if l.after > key && !strings.HasPrefix(l.after, key) {
+				continue
+			}
+			full, err := l.list(filepath.Join(dir, entry.Name()), key)
+			if err != nil || full {
+				return full, err
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(key, ".tmp") {
+			continue
+		}
+		if key <= l.after || !strings.HasPrefix(key, l.prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return false, err
+		}
+		if err := l.fn(key, info.Size()); err != nil {
+			return false, err
+		}
+		l.n++
+		if l.limit > 0 && l.n == l.limit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d Directory) Stat(key string) (EntryInfo, bool) {
+	path, ok := d.path(key)
+	if !ok {
+		return EntryInfo{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return EntryInfo{}, false
+	}
+	return EntryInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Metadata: map[string]string{
+			"path": path,
+		},
+	}, true
+}
+
+// maxSegmentLength leaves room within the usual 255 byte file name limit
+// for the suffix that writeToCompletion appends to temporary files.
+const maxSegmentLength = 200
+
+// path maps the key to a file below the directory.
+// Keys are slash separated, and any key whose segments could escape the directory
+// or are not portable file names is refused rather than rewritten,
+// so that every file below the directory maps back to exactly one key.
+func (d Directory) path(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if !validSegment(segment) {
+			return "", false
+		}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(key)), true
+}
+
+func validSegment(segment string) bool {
+	switch segment {
+	case "", ".", "..":
+		return false
+	}
+	if len(segment) > maxSegmentLength {
+		return false
+	}
+	if strings.HasSuffix(segment, ".tmp") {
+		return false
+	}
+	return !strings.ContainsAny(segment, "\\\x00")
 }
 
 func writeToCompletion(path string, mode os.FileMode) (io.WriteCloser, error) {
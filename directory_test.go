@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryRejectsUnsafeKeys(t *testing.T) {
+	root := t.TempDir()
+	d := Directory(filepath.Join(root, "cache"))
+	keys := []string{
+		"",
+		"..",
+		"../escape",
+		"host/../../escape",
+		"/absolute",
+		"host/",
+		"host//path",
+		"./key",
+		"nul\x00byte",
+		`back\slash`,
+		"key.tmp",
+		strings.Repeat("a", maxSegmentLength+1),
+	}
+	for _, key := range keys {
+		if w, ok := d.Put(key); ok {
+			w.Close()
+			t.Errorf("expected key %q to be refused", key)
+		}
+		if _, ok := d.Get(key); ok {
+			t.Errorf("expected key %q to be unavailable", key)
+		}
+	}
+}
+
+func TestDirectoryHostKey(t *testing.T) {
+	d := Directory(t.TempDir())
+	key := "127.0.0.1:8080/Lw"
+	w, ok := d.Put(key)
+	if !ok {
+		t.Fatal("expected to get the writer")
+	}
+	w.Write([]byte("OK"))
+	w.Close()
+	r, ok := d.Get(key)
+	if !ok {
+		t.Fatal("expected to be available")
+	}
+	data, _ := io.ReadAll(r)
+	if string(data) != "OK" {
+		t.Fatalf("want %q, got %q", "OK", data)
+	}
+}
+
+func FuzzDirectoryPath(f *testing.F) {
+	for _, key := range []string{"host/path", "..", "../..", "a/../../b", "/etc/passwd", "C:/x", `..\..`, "a\x00b"} {
+		f.Add(key)
+	}
+	root := filepath.Join("root", "cache")
+	d := Directory(root)
+	f.Fuzz(func(t *testing.T, key string) {
+		path, ok := d.path(key)
+		if !ok {
+			return
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatalf("key %q maps to %q: %s", key, path, err)
+		}
+		if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			t.Fatalf("key %q escapes the directory as %q", key, path)
+		}
+		if filepath.ToSlash(rel) != key {
+			t.Fatalf("key %q is not preserved, got %q", key, rel)
+		}
+	})
+}
@@ -1,8 +1,129 @@
 package httpcache
 
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
 func NormalDiscarder() Discarder {
 	return DiscarderFunc(func(resp Response) bool {
 		code := resp.StatusCode()
 		return code < 200 || 500 <= code
 	})
 }
+
+func AndJointDiscarder(discarders ...Discarder) Discarder {
+	switch len(discarders) {
+	case 0:
+		return nil
+	case 1:
+		return discarders[0]
+	}
+	return jointDiscarder(discarders, func(discard func(discarder Discarder) bool) bool {
+		for _, discarder := range discarders {
+			if !discard(discarder) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func OrJointDiscarder(discarders ...Discarder) Discarder {
+	switch len(discarders) {
+	case 0:
+		return nil
+	case 1:
+		return discarders[0]
+	}
+	return jointDiscarder(discarders, func(discard func(discarder Discarder) bool) bool {
+		for _, discarder := range discarders {
+			if discard(discarder) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func NotDiscarder(discarder Discarder) Discarder {
+	return jointDiscarder([]Discarder{discarder}, func(discard func(discarder Discarder) bool) bool {
+		return !discard(discarder)
+	})
+}
+
+// jointDiscarder is a BodyDiscarder if any of the discarders is one,
+// so that the body is only buffered for the discarders that need it.
+func jointDiscarder(discarders []Discarder, joint func(discard func(discarder Discarder) bool) bool) Discarder {
+	for _, discarder := range discarders {
+		if _, ok := discarder.(BodyDiscarder); ok {
+			return BodyDiscarderFunc(func(resp Response, body []byte) bool {
+				return joint(func(discarder Discarder) bool {
+					if bodyDiscarder, ok := discarder.(BodyDiscarder); ok {
+						return bodyDiscarder.DiscardBody(resp, body)
+					}
+					return discarder.Discard(resp)
+				})
+			})
+		}
+	}
+	return DiscarderFunc(func(resp Response) bool {
+		return joint(func(discarder Discarder) bool {
+			return discarder.Discard(resp)
+		})
+	})
+}
+
+// StatusDiscarder discards responses with any of the status codes,
+// wrap it with NotDiscarder to only keep responses with the status codes.
+func StatusDiscarder(codes ...int) Discarder {
+	set := map[int]struct{}{}
+	for _, code := range codes {
+		set[code] = struct{}{}
+	}
+	return DiscarderFunc(func(resp Response) bool {
+		_, ok := set[resp.StatusCode()]
+		return ok
+	})
+}
+
+// ContentTypeDiscarder discards responses with any of the media types,
+// a type such as "image/*" matches all of its subtypes.
+func ContentTypeDiscarder(types ...string) Discarder {
+	return DiscarderFunc(func(resp Response) bool {
+		mediaType, _, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, t := range types {
+			t = strings.ToLower(t)
+			if t == mediaType {
+				return true
+			}
+			if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// HeaderDiscarder discards responses carrying the header,
+// with any of the values if some are given, e.g. HeaderDiscarder("Set-Cookie").
+func HeaderDiscarder(name string, values ...string) Discarder {
+	return DiscarderFunc(func(resp Response) bool {
+		got, ok := resp.Header()[http.CanonicalHeaderKey(name)]
+		return ok && matchValues(got, values)
+	})
+}
+
+// SizeDiscarder discards responses whose Content-Length is larger than size bytes,
+// responses without a Content-Length are kept.
+func SizeDiscarder(size int64) Discarder {
+	return DiscarderFunc(func(resp Response) bool {
+		length, err := strconv.ParseInt(resp.Header().Get("Content-Length"), 10, 64)
+		return err == nil && length > size
+	})
+}
@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSONPathDiscarder discards JSON responses that have a value other than null, false, "", [] or {}
+// at any of the dot separated paths, such as "error" or "data.errors.0.message".
+// Bodies larger than limit bytes are not inspected and kept.
+func JSONPathDiscarder(limit int, paths ...string) Discarder {
+	split := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		split = append(split, strings.Split(path, "."))
+	}
+	return BodyDiscarderFunc(func(resp Response, body []byte) bool {
+		if len(body) == 0 || len(body) > limit || !isJSONResponse(resp) {
+			return false
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return false
+		}
+		for _, path := range split {
+			if isJSONTruthy(lookupJSONPath(v, path)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RegexpBodyDiscarder discards responses whose first limit bytes of body match the regexp.
+func RegexpBodyDiscarder(re *regexp.Regexp, limit int) Discarder {
+	return BodyDiscarderFunc(func(resp Response, body []byte) bool {
+		if len(body) > limit {
+			body = body[:limit]
+		}
+		return re.Match(body)
+	})
+}
+
+func isJSONResponse(resp Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func lookupJSONPath(v interface{}, path []string) interface{} {
+	for _, elem := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[elem]
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func isJSONTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) != 0
+	case map[string]interface{}:
+		return len(v) != 0
+	}
+	return true
+}
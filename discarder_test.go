@@ -0,0 +1,105 @@
+package httpcache
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+type testResponse struct {
+	header http.Header
+	code   int
+}
+
+func (r testResponse) Header() http.Header {
+	return r.header
+}
+
+func (r testResponse) StatusCode() int {
+	return r.code
+}
+
+func newTestResponse(code int, header ...string) testResponse {
+	resp := testResponse{
+		header: http.Header{},
+		code:   code,
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		resp.header.Add(header[i], header[i+1])
+	}
+	return resp
+}
+
+func TestDiscarders(t *testing.T) {
+	tests := []struct {
+		name      string
+		discarder Discarder
+		resp      Response
+		want      bool
+	}{
+		{"status denylist", StatusDiscarder(404, 410), newTestResponse(404), true},
+		{"status denylist other", StatusDiscarder(404, 410), newTestResponse(200), false},
+		{"status allowlist", NotDiscarder(StatusDiscarder(200, 301)), newTestResponse(404), true},
+		{"status allowlist other", NotDiscarder(StatusDiscarder(200, 301)), newTestResponse(301), false},
+		{"content type", ContentTypeDiscarder("text/event-stream"), newTestResponse(200, "Content-Type", "text/event-stream; charset=utf-8"), true},
+		{"content type wildcard", ContentTypeDiscarder("video/*"), newTestResponse(200, "Content-Type", "video/mp4"), true},
+		{"content type other", ContentTypeDiscarder("video/*"), newTestResponse(200, "Content-Type", "text/html"), false},
+		{"content type missing", ContentTypeDiscarder("video/*"), newTestResponse(200), false},
+		{"header", HeaderDiscarder("set-cookie"), newTestResponse(200, "Set-Cookie", "session=1"), true},
+		{"header missing", HeaderDiscarder("Set-Cookie"), newTestResponse(200), false},
+		{"header value", HeaderDiscarder("X-Cache", "skip"), newTestResponse(200, "X-Cache", "skip"), true},
+		{"size", SizeDiscarder(10), newTestResponse(200, "Content-Length", "11"), true},
+		{"size within", SizeDiscarder(10), newTestResponse(200, "Content-Length", "10"), false},
+		{"size unknown", SizeDiscarder(10), newTestResponse(200), false},
+		{"or", OrJointDiscarder(NormalDiscarder(), HeaderDiscarder("Set-Cookie")), newTestResponse(200, "Set-Cookie", "a=1"), true},
+		{"and", AndJointDiscarder(StatusDiscarder(404), HeaderDiscarder("Set-Cookie")), newTestResponse(404), false},
+		{"and all", AndJointDiscarder(StatusDiscarder(404), HeaderDiscarder("Set-Cookie")), newTestResponse(404, "Set-Cookie", "a=1"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.discarder.Discard(tt.resp); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBodyDiscarders(t *testing.T) {
+	jsonResp := newTestResponse(200, "Content-Type", "application/json; charset=utf-8")
+	tests := []struct {
+		name      string
+		discarder Discarder
+		resp      Response
+		body      string
+		want      bool
+	}{
+		{"json error", JSONPathDiscarder(1024, "error"), jsonResp, `{"error":{"code":1}}`, true},
+		{"json null error", JSONPathDiscarder(1024, "error"), jsonResp, `{"error":null,"data":1}`, false},
+		{"json nested", JSONPathDiscarder(1024, "error", "data.errors"), jsonResp, `{"data":{"errors":["x"]}}`, true},
+		{"json empty errors", JSONPathDiscarder(1024, "data.errors"), jsonResp, `{"data":{"errors":[]}}`, false},
+		{"json index", JSONPathDiscarder(1024, "errors.0.message"), jsonResp, `{"errors":[{"message":"x"}]}`, true},
+		{"json over limit", JSONPathDiscarder(8, "error"), jsonResp, `{"error":{"code":1}}`, false},
+		{"json not json", JSONPathDiscarder(1024, "error"), newTestResponse(200, "Content-Type", "text/plain"), `{"error":1}`, false},
+		{"regexp", RegexpBodyDiscarder(regexp.MustCompile(`Service Unavailable`), 64), newTestResponse(200), "<h1>Service Unavailable</h1>", true},
+		{"regexp beyond prefix", RegexpBodyDiscarder(regexp.MustCompile(`Service Unavailable`), 8), newTestResponse(200), "<h1>Service Unavailable</h1>", false},
+		{"or", OrJointDiscarder(NormalDiscarder(), JSONPathDiscarder(1024, "error")), jsonResp, `{"error":1}`, true},
+		{"or status", OrJointDiscarder(NormalDiscarder(), JSONPathDiscarder(1024, "error")), newTestResponse(500), `{}`, true},
+		{"and", AndJointDiscarder(StatusDiscarder(200), JSONPathDiscarder(1024, "error")), jsonResp, `{"data":1}`, false},
+		{"not", NotDiscarder(JSONPathDiscarder(1024, "data")), jsonResp, `{"error":1}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyDiscarder, ok := tt.discarder.(BodyDiscarder)
+			if !ok {
+				t.Fatal("expected a BodyDiscarder")
+			}
+			if got := bodyDiscarder.DiscardBody(tt.resp, []byte(tt.body)); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, ok := OrJointDiscarder(NormalDiscarder(), StatusDiscarder(404)).(BodyDiscarder); ok {
+		t.Error("expected the joint discarder not to need the body")
+	}
+}
@@ -0,0 +1,346 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata of the entries is stored along with the response in these headers,
+// which are removed before the response is served.
+const (
+	headerStored         = "X-Httpcache-Stored"
+	headerTTL            = "X-Httpcache-Ttl"
+	headerHeuristic      = "X-Httpcache-Heuristic"
+	headerMustRevalidate = "X-Httpcache-Must-Revalidate"
+)
+
+// cacheStatusName identifies this cache in the Cache-Status header of RFC 9211.
+const cacheStatusName = "httpcache"
+
+// entryMeta describes when an entry was stored and for how long it is fresh.
+type entryMeta struct {
+	stored    time.Time
+	age       time.Duration
+	ttl       time.Duration
+	expires   bool
+	heuristic bool
+	// mustRevalidate forbids serving the entry once stale.
+	mustRevalidate bool
+}
+
+// currentAge adds the time spent in the cache to the age the response had when stored.
+func (m entryMeta) currentAge(now time.Time) time.Duration {
+	return m.age + now.Sub(m.stored)
+}
+
+func (m entryMeta) fresh(now time.Time) bool {
+	return !m.expires || m.currentAge(now) < m.ttl
+}
+
+func (m entryMeta) set(header http.Header) {
+	header.Set(headerStored, strconv.FormatInt(m.stored.Unix(), 10))
+	if m.expires {
+		header.Set(headerTTL, strconv.FormatInt(int64(m.ttl/time.Second), 10))
+	}
+	if m.heuristic {
+		header.Set(headerHeuristic, "1")
+	}
+	if m.mustRevalidate {
+		header.Set(headerMustRevalidate, "1")
+	}
+}
+
+// setHit marks a response served from the cache with its Age and Cache-Status.
+func (m entryMeta) setHit(header http.Header, now time.Time) {
+	age := m.currentAge(now)
+	if age < 0 {
+		age = 0
+	}
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	status := cacheStatusName + "; hit"
+	if m.expires {
+		status += "; ttl=" + strconv.FormatInt(int64((m.ttl-age)/time.Second), 10)
+	}
+	if m.heuristic {
+		// The equivalent of the obsolete 113 Heuristic Expiration warning.
+		status += "; detail=heuristic"
+	}
+	header.Add("Cache-Status", status)
+}
+
+// popEntryMeta reads the metadata from the header and removes it.
+func popEntryMeta(header http.Header) entryMeta {
+	var m entryMeta
+	if stored, err := strconv.ParseInt(header.Get(headerStored), 10, 64); err == nil {
+		m.stored = time.Unix(stored, 0)
+	}
+	if ttl, err := strconv.ParseInt(header.Get(headerTTL), 10, 64); err == nil {
+		m.ttl = time.Duration(ttl) * time.Second
+		m.expires = true
+	}
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+		m.age = time.Duration(age) * time.Second
+	}
+	m.heuristic = header.Get(headerHeuristic) != ""
+	m.mustRevalidate = header.Get(headerMustRevalidate) != ""
+	header.Del(headerStored)
+	header.Del(headerTTL)
+	header.Del(headerHeuristic)
+	header.Del(headerMustRevalidate)
+	return m
+}
+
+// lookup is the outcome of looking up an entry.
+type lookup int
+
+const (
+	lookupMiss lookup = iota
+	lookupRejected
+	lookupHit
+	lookupStaleHit
+)
+
+func (l lookup) String() string {
+	switch l {
+	case lookupRejected:
+		return "rejected"
+	case lookupHit:
+		return "hit"
+	case lookupStaleHit:
+		return "stale_hit"
+	}
+	return "miss"
+}
+
+// load returns the response stored under the key if its metadata is accepted,
+// such as by entryMeta.fresh. Closing its body releases the entry.
+// Entries that cannot be read back are removed.
+func (o *option) load(req *http.Request, key string, accept func(meta entryMeta, now time.Time) bool) (*http.Response, lookup) {
+	_, span := o.startSpan(req.Context(), "httpcache.lookup")
+	defer span.End()
+	span.SetAttribute(AttributeKey, key)
+	resp, result := o.loadEntry(req, key, accept)
+	span.SetAttribute(AttributeOutcome, result.String())
+	if resp != nil {
+		span.SetAttribute(AttributeBytes, resp.ContentLength)
+	}
+	return resp, result
+}
+
+func (o *option) loadEntry(req *http.Request, key string, accept func(meta entryMeta, now time.Time) bool) (*http.Response, lookup) {
+	data, ok := o.storer.Get(key)
+	if !ok {
+		return nil, lookupMiss
+	}
+	resp, err := unmarshalResponse(data)
+	if err != nil {
+		data.Close()
+		o.log(req, slog.LevelWarn, "corrupt entry", key, err)
+		o.evict(req, key)
+		return nil, lookupMiss
+	}
+	meta := popEntryMeta(resp.Header)
+	now := o.now()
+	if !accept(meta, now) {
+		resp.Body.Close()
+		data.Close()
+		return nil, lookupRejected
+	}
+	meta.setHit(resp.Header, now)
+	resp.ContentLength = -1
+	if length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = length
+	}
+	body := resp.Body
+	resp.Body = &readerWithClose{
+		Reader: body,
+		close: func() error {
+			body.Close()
+			return data.Close()
+		},
+	}
+	if !meta.fresh(now) {
+		return resp, lookupStaleHit
+	}
+	return resp, lookupHit
+}
+
+// hit reports the response loaded for the key.
+func (o *option) hit(req *http.Request, key string, resp *http.Response, result lookup) {
+	kind := EventHit
+	if result == lookupStaleHit {
+		kind = EventStaleHit
+	}
+	bytes := resp.ContentLength
+	if bytes < 0 {
+		bytes = 0
+	}
+	o.observe(req, Event{Kind: kind, Key: key, Bytes: bytes})
+}
+
+// fetch reports a request sent to the origin after the lookup of its entry,
+// and starts the span of the request, which the caller ends.
+// The returned request carries the context of the span.
+func (o *option) fetch(req *http.Request, key string, result lookup) (*http.Request, Span) {
+	kind := EventMiss
+	if result == lookupRejected {
+		kind = EventRefetch
+	}
+	o.observe(req, Event{Kind: kind, Key: key})
+	ctx, span := o.startSpan(req.Context(), "httpcache.fetch")
+	span.SetAttribute(AttributeKey, key)
+	span.SetAttribute(AttributeOutcome, kind.String())
+	if ctx != req.Context() {
+		req = req.WithContext(ctx)
+	}
+	return req, span
+}
+
+// evict removes the entry of the key.
+func (o *option) evict(req *http.Request, key string) {
+	if o.storer.Del(key) {
+		o.observe(req, Event{Kind: EventEviction, Key: key})
+	}
+}
+
+// admitHeader tells from its header whether the response to the request may be stored.
+func (o *option) admitHeader(req *http.Request, resp Response) bool {
+	if !o.storable(req, resp) {
+		return false
+	}
+	if _, ok := o.discarder.(BodyDiscarder); ok {
+		return true
+	}
+	return !o.discarder.Discard(resp)
+}
+
+// admit tells from its buffered body whether the response to the request,
+// already admitted by admitHeader, is stored, and under which key and with which metadata.
+func (o *option) admit(req *http.Request, resp Response, body []byte, key string) (string, entryMeta, bool) {
+	if bodyDiscarder, ok := o.discarder.(BodyDiscarder); ok && bodyDiscarder.DiscardBody(resp, body) {
+		return "", entryMeta{}, false
+	}
+	meta, ok := o.entryMeta(resp)
+	if !ok {
+		return "", entryMeta{}, false
+	}
+	if varier, ok := o.keyer.(Varier); ok {
+		key, ok = varier.VaryKey(req, key, resp)
+		if !ok {
+			return "", entryMeta{}, false
+		}
+	}
+	return key, meta, true
+}
+
+var errStoreRefused = errors.New("storer refused the entry")
+
+// store writes the response with the body and its metadata under the key,
+// start is when the response started to be fetched.
+func (o *option) store(req *http.Request, key string, resp *http.Response, body []byte, meta entryMeta, start time.Time) error {
+	_, span := o.startSpan(req.Context(), "httpcache.store")
+	defer span.End()
+	span.SetAttribute(AttributeKey, key)
+	span.SetAttribute(AttributeBytes, int64(len(body)))
+	err := o.put(key, resp, body, meta)
+	if err != nil {
+		span.RecordError(err)
+		level := slog.LevelError
+		if err == errStoreRefused {
+			level = slog.LevelWarn
+		}
+		o.log(req, level, "failed to store the entry", key, err)
+		o.observe(req, Event{Kind: EventStoreFailure, Key: key})
+		return err
+	}
+	o.observe(req, Event{Kind: EventStore, Key: key, Bytes: int64(len(body)), Duration: time.Since(start)})
+	return nil
+}
+
+func (o *option) put(key string, resp *http.Response, body []byte, meta entryMeta) error {
+	buf, ok := o.storer.Put(key)
+	if !ok {
+		return errStoreRefused
+	}
+	stored := *resp
+	stored.Header = o.sanitizeHeader(resp.Header)
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	stored.Close = false
+	meta.set(stored.Header)
+	err := marshalResponse(&stored, buf)
+	if err != nil {
+		buf.Close()
+		o.storer.Del(key)
+		return err
+	}
+	err = buf.Close()
+	if err != nil {
+		o.storer.Del(key)
+		return err
+	}
+	return nil
+}
+
+// hopByHopHeaders only concern a single connection and are never stored,
+// as in RFC 9110 section 7.6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// sanitizeHeader returns a copy of the header without the hop-by-hop headers,
+// the headers named in Connection and the headers configured to be stripped.
+func (o *option) sanitizeHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+	if o.mode == SharedMode && !o.keepSetCookie {
+		for _, name := range defaultSharedStripHeaders {
+			header.Del(name)
+		}
+	}
+	for _, name := range o.stripHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+// defaultSharedStripHeaders are never replayed to other users of a shared cache.
+var defaultSharedStripHeaders = []string{"Set-Cookie"}
+
+// serveResponse writes the response to rw and closes its body.
+func serveResponse(rw http.ResponseWriter, resp *http.Response) error {
+	defer resp.Body.Close()
+	header := rw.Header()
+	for key, values := range resp.Header {
+		header[key] = values
+	}
+	rw.WriteHeader(resp.StatusCode)
+	buf := getBytes()
+	defer putBytes(buf)
+	_, err := io.CopyBuffer(rw, resp.Body, buf)
+	return err
+}
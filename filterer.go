@@ -1,7 +1,9 @@
 package httpcache
 
 import (
+	"net"
 	"net/http"
+	"regexp"
 	"strings"
 )
 
@@ -39,6 +41,12 @@ func OrJointFilterer(filterers ...Filterer) Filterer {
 	})
 }
 
+func NotFilterer(filterer Filterer) Filterer {
+	return FiltererFunc(func(req *http.Request) bool {
+		return !filterer.Filter(req)
+	})
+}
+
 func MethodFilterer(ms ...string) Filterer {
 	methods := map[string]struct{}{}
 	for _, m := range ms {
@@ -55,3 +63,85 @@ func PrefixFilterer(prefix string) Filterer {
 		return strings.HasPrefix(req.URL.Path, prefix)
 	})
 }
+
+// RegexpFilterer accepts requests whose path matches the regexp.
+func RegexpFilterer(re *regexp.Regexp) Filterer {
+	return FiltererFunc(func(req *http.Request) bool {
+		return re.MatchString(req.URL.Path)
+	})
+}
+
+// HostFilterer accepts requests for any of the hosts, ignoring case and port.
+// A host starting with "*." matches the subdomains of the domain at any depth but not the domain itself.
+func HostFilterer(hosts ...string) Filterer {
+	exact := map[string]struct{}{}
+	var suffixes []string
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if strings.HasPrefix(host, "*.") {
+			suffixes = append(suffixes, host[1:])
+		} else {
+			exact[host] = struct{}{}
+		}
+	}
+	return FiltererFunc(func(req *http.Request) bool {
+		host := strings.ToLower(requestHost(req))
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(host, ".")
+		if _, ok := exact[host]; ok {
+			return true
+		}
+		for _, suffix := range suffixes {
+			if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// HeaderFilterer accepts requests carrying the header,
+// with any of the values if some are given.
+func HeaderFilterer(name string, values ...string) Filterer {
+	return FiltererFunc(func(req *http.Request) bool {
+		got, ok := req.Header[http.CanonicalHeaderKey(name)]
+		return ok && matchValues(got, values)
+	})
+}
+
+// QueryFilterer accepts requests carrying the query parameter,
+// with any of the values if some are given.
+func QueryFilterer(name string, values ...string) Filterer {
+	return FiltererFunc(func(req *http.Request) bool {
+		got, ok := req.URL.Query()[name]
+		return ok && matchValues(got, values)
+	})
+}
+
+// SchemeFilterer accepts requests made with any of the schemes, such as "https".
+func SchemeFilterer(schemes ...string) Filterer {
+	set := map[string]struct{}{}
+	for _, scheme := range schemes {
+		set[strings.ToLower(scheme)] = struct{}{}
+	}
+	return FiltererFunc(func(req *http.Request) bool {
+		_, ok := set[canonicalScheme(req)]
+		return ok
+	})
+}
+
+func matchValues(got, values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range got {
+		for _, want := range values {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestFilterers(t *testing.T) {
+	newRequest := func(url string, header ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Add(header[i], header[i+1])
+		}
+		return req
+	}
+	tests := []struct {
+		name     string
+		filterer Filterer
+		req      *http.Request
+		want     bool
+	}{
+		{"not", NotFilterer(PrefixFilterer("/api")), newRequest("http://example.com/api/x"), false},
+		{"regexp", RegexpFilterer(regexp.MustCompile(`\.(css|js)$`)), newRequest("http://example.com/a/b.js"), true},
+		{"regexp no match", RegexpFilterer(regexp.MustCompile(`\.(css|js)$`)), newRequest("http://example.com/a/b.json"), false},
+		{"host", HostFilterer("example.com"), newRequest("http://EXAMPLE.com:8080/"), true},
+		{"host other", HostFilterer("example.com"), newRequest("http://example.org/"), false},
+		{"host wildcard", HostFilterer("*.example.com"), newRequest("http://a.b.example.com/"), true},
+		{"host wildcard apex", HostFilterer("*.example.com"), newRequest("http://example.com/"), false},
+		{"host wildcard suffix", HostFilterer("*.example.com"), newRequest("http://badexample.com/"), false},
+		{"header presence", HeaderFilterer("authorization"), newRequest("http://example.com/", "Authorization", "Bearer x"), true},
+		{"header absence", HeaderFilterer("Authorization"), newRequest("http://example.com/"), false},
+		{"header value", HeaderFilterer("X-Tenant", "a", "b"), newRequest("http://example.com/", "X-Tenant", "b"), true},
+		{"header other value", HeaderFilterer("X-Tenant", "a"), newRequest("http://example.com/", "X-Tenant", "b"), false},
+		{"skip authorization", NotFilterer(HeaderFilterer("Authorization")), newRequest("http://example.com/", "Authorization", "Bearer x"), false},
+		{"query presence", QueryFilterer("nocache"), newRequest("http://example.com/?nocache"), true},
+		{"query value", QueryFilterer("v", "2"), newRequest("http://example.com/?v=1&v=2"), true},
+		{"query other value", QueryFilterer("v", "3"), newRequest("http://example.com/?v=1&v=2"), false},
+		{"scheme", SchemeFilterer("HTTPS"), newRequest("https://example.com/"), true},
+		{"scheme other", SchemeFilterer("https"), newRequest("http://example.com/"), false},
+		{
+			"joint",
+			AndJointFilterer(
+				MethodFilterer(http.MethodGet),
+				OrJointFilterer(HostFilterer("*.example.com"), PrefixFilterer("/static/")),
+				NotFilterer(HeaderFilterer("Authorization")),
+			),
+			newRequest("http://example.com/static/a.css"),
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filterer.Filter(tt.req); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
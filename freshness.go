@@ -0,0 +1,205 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusTTL maps status codes to how long their responses stay fresh,
+// taking precedence over the freshness the responses carry.
+// The keys 1 to 5 stand for a whole class of status codes, e.g. 5 for 5xx,
+// and exact status codes take precedence over their class.
+// A TTL of zero or less means the responses are never stored.
+type StatusTTL map[int]time.Duration
+
+func (s StatusTTL) lookup(code int) (time.Duration, bool) {
+	if ttl, ok := s[code]; ok {
+		return ttl, true
+	}
+	if ttl, ok := s[code/100]; ok && code >= 100 {
+		return ttl, true
+	}
+	return 0, false
+}
+
+// heuristicStatusCodes are the status codes that are heuristically cacheable,
+// as listed in RFC 9110 section 15.1.
+var heuristicStatusCodes = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusPartialContent:       {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusPermanentRedirect:    {},
+	http.StatusNotFound:             {},
+	http.StatusMethodNotAllowed:     {},
+	http.StatusGone:                 {},
+	http.StatusRequestURITooLong:    {},
+	http.StatusNotImplemented:       {},
+}
+
+// entryMeta returns the metadata to store the response with,
+// or false if the response must not be stored.
+// The freshness lifetime comes from, in order, the StatusTTL, the s-maxage directive in SharedMode, the max-age directive,
+// the Expires header, and the heuristic of RFC 9111 section 4.2.2 for responses with a Last-Modified header.
+// Responses with none of these never expire.
+func (o *option) entryMeta(resp Response) (entryMeta, bool) {
+	meta := entryMeta{
+		stored: o.now(),
+	}
+	header := resp.Header()
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	meta.mustRevalidate = directives.has("must-revalidate") || (o.mode == SharedMode && directives.has("proxy-revalidate"))
+	if ttl, ok := o.statusTTL.lookup(resp.StatusCode()); ok {
+		if ttl <= 0 {
+			return meta, false
+		}
+		meta.ttl = ttl
+		meta.expires = true
+		return meta, true
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = meta.stored
+	}
+
+	if sMaxAge, ok := directives.seconds("s-maxage"); ok && o.mode == SharedMode {
+		meta.ttl = sMaxAge
+		meta.expires = true
+		return meta, true
+	}
+	if maxAge, ok := directives.seconds("max-age"); ok {
+		meta.ttl = maxAge
+		meta.expires = true
+		return meta, true
+	}
+
+	if values := header.Values("Expires"); len(values) != 0 {
+		meta.expires = true
+		// Invalid dates, such as 0, mean the response is already expired.
+		if expires, err := http.ParseTime(values[0]); err == nil && expires.After(date) {
+			meta.ttl = expires.Sub(date)
+		}
+		return meta, true
+	}
+
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && o.heuristicFraction > 0 {
+		if _, ok := heuristicStatusCodes[resp.StatusCode()]; ok {
+			ttl := time.Duration(float64(date.Sub(lastModified)) * o.heuristicFraction)
+			if ttl > o.heuristicMax {
+				ttl = o.heuristicMax
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+			meta.ttl = ttl.Truncate(time.Second)
+			meta.expires = true
+			meta.heuristic = true
+			return meta, true
+		}
+	}
+	return meta, true
+}
+
+// cacheControl holds the directives of Cache-Control headers, mapped to their arguments.
+type cacheControl map[string]string
+
+func parseCacheControl(values []string) cacheControl {
+	directives := cacheControl{}
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.IndexByte(directive, '='); i != -1 {
+				name, arg = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, ok := directives[name]; ok {
+				continue
+			}
+			directives[name] = arg
+		}
+	}
+	return directives
+}
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+// seconds returns the argument of the directive as a duration,
+// invalid arguments are reported as zero as RFC 9111 section 1.2.2 recommends.
+func (c cacheControl) seconds(name string) (time.Duration, bool) {
+	arg, ok := c[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, true
+	}
+	if n > maxDeltaSeconds {
+		n = maxDeltaSeconds
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// maxDeltaSeconds caps delta-seconds as RFC 9111 section 1.2.2 allows.
+const maxDeltaSeconds = 1<<31 - 1
+
+// requestDirectives are the Cache-Control directives of a request that select acceptable entries,
+// as in RFC 9111 section 5.2.1.
+type requestDirectives struct {
+	maxAge       time.Duration
+	hasMaxAge    bool
+	maxStale     time.Duration
+	hasMaxStale  bool
+	minFresh     time.Duration
+	hasMinFresh  bool
+	noCache      bool
+	onlyIfCached bool
+}
+
+func parseRequestDirectives(header http.Header) requestDirectives {
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	var d requestDirectives
+	d.maxAge, d.hasMaxAge = directives.seconds("max-age")
+	d.minFresh, d.hasMinFresh = directives.seconds("min-fresh")
+	if arg, ok := directives["max-stale"]; ok {
+		d.hasMaxStale = true
+		d.maxStale = maxDeltaSeconds * time.Second
+		if arg != "" {
+			d.maxStale, _ = directives.seconds("max-stale")
+		}
+	}
+	d.noCache = directives.has("no-cache")
+	d.onlyIfCached = directives.has("only-if-cached")
+	return d
+}
+
+// accept reports whether an entry satisfies the directives.
+func (d requestDirectives) accept(meta entryMeta, now time.Time) bool {
+	age := meta.currentAge(now)
+	if d.hasMaxAge && age > d.maxAge {
+		return false
+	}
+	if !meta.expires {
+		return true
+	}
+	remaining := meta.ttl - age
+	if d.hasMinFresh && remaining < d.minFresh {
+		return false
+	}
+	if remaining > 0 {
+		return true
+	}
+	return !meta.mustRevalidate && d.hasMaxStale && -remaining <= d.maxStale
+}
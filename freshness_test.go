@@ -0,0 +1,296 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	mut sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{
+		now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func withClock(clock *testClock) func(c *option) {
+	return func(c *option) {
+		c.now = clock.Now
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// testOrigin counts the requests per path, and responds with the status code in the path
+// and the number of requests so far in the body.
+type testOrigin struct {
+	mut    sync.Mutex
+	counts map[string]int
+	header http.Header
+}
+
+func newTestOrigin(header ...string) *testOrigin {
+	o := &testOrigin{
+		counts: map[string]int{},
+		header: http.Header{},
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		o.header.Add(header[i], header[i+1])
+	}
+	return o
+}
+
+func (o *testOrigin) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	o.mut.Lock()
+	o.counts[r.URL.Path]++
+	count := o.counts[r.URL.Path]
+	o.mut.Unlock()
+	for key, values := range o.header {
+		rw.Header()[key] = values
+	}
+	code, err := strconv.Atoi(r.URL.Path[1:])
+	if err != nil {
+		code = http.StatusOK
+	}
+	rw.WriteHeader(code)
+	rw.Write([]byte(strconv.Itoa(count)))
+}
+
+func (o *testOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	rw := httptest.NewRecorder()
+	o.ServeHTTP(rw, req)
+	return rw.Result(), nil
+}
+
+// testCaches returns functions performing requests through a Handler and through a RoundTripper,
+// each in front of its own origin.
+func testCaches(newOrigin func() *testOrigin, options ...Option) map[string]func(req *http.Request) *http.Response {
+	handler := NewHandler(newOrigin(), options...)
+	transport := NewRoundTripper(newOrigin(), options...)
+	return map[string]func(req *http.Request) *http.Response{
+		"Handler": func(req *http.Request) *http.Response {
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			return rw.Result()
+		},
+		"RoundTripper": func(req *http.Request) *http.Response {
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				panic(err)
+			}
+			return resp
+		},
+	}
+}
+
+func readBodyString(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return string(body)
+}
+
+func TestStatusTTL(t *testing.T) {
+	clock := newTestClock()
+	ttl := StatusTTL{
+		200: time.Hour,
+		404: 30 * time.Second,
+		3:   24 * time.Hour,
+		5:   0,
+	}
+	caches := testCaches(func() *testOrigin { return newTestOrigin() }, WithStatusTTL(ttl), WithDiscarder(DiscarderFunc(func(Response) bool { return false })), withClock(clock))
+	for name, do := range caches {
+		t.Run(name, func(t *testing.T) {
+			steps := []struct {
+				advance time.Duration
+				path    string
+				want    string
+			}{
+				{0, "/200", "1"},
+				{0, "/404", "1"},
+				{0, "/301", "1"},
+				{0, "/500", "1"},
+				{10 * time.Second, "/404", "1"},
+				{0, "/500", "2"},
+				{21 * time.Second, "/404", "2"},
+				{0, "/200", "1"},
+				{time.Hour, "/200", "2"},
+				{0, "/301", "1"},
+				{24 * time.Hour, "/301", "2"},
+			}
+			for i, step := range steps {
+				clock.Add(step.advance)
+				resp := do(httptest.NewRequest(http.MethodGet, "http://example.com"+step.path, nil))
+				if got := readBodyString(t, resp); got != step.want {
+					t.Fatalf("step %d %s: want %q, got %q", i, step.path, step.want, got)
+				}
+				if resp.Header.Get(headerStored) != "" {
+					t.Fatalf("step %d %s: metadata leaked", i, step.path)
+				}
+			}
+		})
+	}
+}
+
+func TestExplicitFreshness(t *testing.T) {
+	start := newTestClock().Now()
+	tests := []struct {
+		name   string
+		header []string
+		fresh  time.Duration
+	}{
+		{"max-age", []string{"Cache-Control", "public, max-age=60"}, time.Minute},
+		{"max-age over expires", []string{"Cache-Control", "max-age=60", "Expires", start.Add(time.Hour).Format(http.TimeFormat)}, time.Minute},
+		{"expires", []string{"Date", start.Format(http.TimeFormat), "Expires", start.Add(time.Hour).Format(http.TimeFormat)}, time.Hour},
+		{"age", []string{"Cache-Control", "max-age=60", "Age", "50"}, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		clock := newTestClock()
+		caches := testCaches(func() *testOrigin { return newTestOrigin(tt.header...) }, withClock(clock))
+		for name, do := range caches {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				get := func() string {
+					return readBodyString(t, do(httptest.NewRequest(http.MethodGet, "http://example.com/explicit", nil)))
+				}
+				if got := get(); got != "1" {
+					t.Fatalf("want %q, got %q", "1", got)
+				}
+				clock.Add(tt.fresh - time.Second)
+				if got := get(); got != "1" {
+					t.Fatalf("want fresh %q, got %q", "1", got)
+				}
+				clock.Add(time.Second)
+				if got := get(); got != "2" {
+					t.Fatalf("want stale refetch %q, got %q", "2", got)
+				}
+			})
+		}
+	}
+}
+
+func TestHeuristicFreshness(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	header := []string{
+		"Date", start.Format(http.TimeFormat),
+		"Last-Modified", start.Add(-10 * time.Hour).Format(http.TimeFormat),
+	}
+	caches := testCaches(func() *testOrigin { return newTestOrigin(header...) }, withClock(clock))
+	for name, do := range caches {
+		t.Run(name, func(t *testing.T) {
+			base := clock.Now()
+			get := func() *http.Response {
+				return do(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+			}
+			resp := get()
+			if got := readBodyString(t, resp); got != "1" {
+				t.Fatalf("want %q, got %q", "1", got)
+			}
+			if got := resp.Header.Get("Cache-Status"); got != "" {
+				t.Fatalf("want no Cache-Status on a miss, got %q", got)
+			}
+
+			clock.Add(base.Sub(clock.Now()) + 30*time.Minute)
+			resp = get()
+			if got := readBodyString(t, resp); got != "1" {
+				t.Fatalf("want %q, got %q", "1", got)
+			}
+			if got, want := resp.Header.Get("Cache-Status"), "httpcache; hit; ttl=1800; detail=heuristic"; got != want {
+				t.Fatalf("want %q, got %q", want, got)
+			}
+			if got, want := resp.Header.Get("Age"), "1800"; got != want {
+				t.Fatalf("want %q, got %q", want, got)
+			}
+
+			clock.Add(30 * time.Minute)
+			if got := readBodyString(t, get()); got != "2" {
+				t.Fatalf("want %q, got %q", "2", got)
+			}
+		})
+	}
+}
+
+func TestHeuristicFreshnessMax(t *testing.T) {
+	clock := newTestClock()
+	header := []string{
+		"Last-Modified", clock.Now().Add(-1000 * time.Hour).Format(http.TimeFormat),
+	}
+	caches := testCaches(func() *testOrigin { return newTestOrigin(header...) }, withClock(clock), WithHeuristicFreshness(0.5, time.Minute))
+	for name, do := range caches {
+		t.Run(name, func(t *testing.T) {
+			get := func() string {
+				return readBodyString(t, do(httptest.NewRequest(http.MethodGet, "http://example.com/", nil)))
+			}
+			get()
+			clock.Add(time.Minute)
+			if got := get(); got != "2" {
+				t.Fatalf("want %q, got %q", "2", got)
+			}
+		})
+	}
+}
+
+func TestResponseNoCache(t *testing.T) {
+	caches := testCaches(func() *testOrigin { return newTestOrigin("Cache-Control", "no-cache") })
+	for name, do := range caches {
+		t.Run(name, func(t *testing.T) {
+			for _, want := range []string{"1", "2", "3"} {
+				if got := readBodyString(t, do(httptest.NewRequest(http.MethodGet, "/200", nil))); got != want {
+					t.Fatalf("want %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMustRevalidate(t *testing.T) {
+	for _, cacheControl := range []string{"max-age=60, must-revalidate", "max-age=60, proxy-revalidate"} {
+		t.Run(cacheControl, func(t *testing.T) {
+			clock := newTestClock()
+			transport := NewRoundTripper(newTestOrigin("Cache-Control", cacheControl), withClock(clock), WithMode(SharedMode))
+			get := func() string {
+				req := httptest.NewRequest(http.MethodGet, "/200", nil)
+				req.Header.Set("Cache-Control", "max-stale")
+				resp, err := transport.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return readBodyString(t, resp)
+			}
+			if got := get(); got != "1" {
+				t.Fatalf("want %q, got %q", "1", got)
+			}
+			clock.Add(70 * time.Second)
+			if got := get(); got != "2" {
+				t.Fatalf("want the stale entry not to be served, got %q", got)
+			}
+		})
+	}
+}
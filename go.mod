@@ -1,3 +1,3 @@
 module github.com/wzshiming/httpcache
 
-go 1.18
+go 1.21
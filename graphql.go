@@ -0,0 +1,276 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// GraphQLFilterer only accepts GraphQL requests, sent via GET or POST, that perform a query operation,
+// mutations and subscriptions are never cached.
+// Persisted queries sent by their hash alone are only accepted via GET,
+// which is how clients send persisted queries that are safe to cache.
+// POST bodies larger than maxSize bytes are rejected.
+func GraphQLFilterer(maxSize int64) Filterer {
+	return FiltererFunc(func(req *http.Request) bool {
+		gql, ok := parseGraphQLRequest(req, maxSize)
+		if !ok {
+			return false
+		}
+		if gql.Query == "" {
+			return gql.persistedQueryHash() != "" && req.Method == http.MethodGet
+		}
+		tokens, err := lexGraphQL(gql.Query)
+		if err != nil {
+			return false
+		}
+		return graphQLOperationType(tokens, gql.OperationName) == "query"
+	})
+}
+
+// GraphQLKeyer keys GraphQL requests, see Keyers.GraphQL.
+func GraphQLKeyer(maxSize int64) Keyer {
+	return defaultKeyers.GraphQL(maxSize)
+}
+
+// GraphQL keys GraphQL requests on the query text with comments and insignificant whitespace removed,
+// the operation name and the canonical JSON of the variables.
+// Persisted queries sent without the query text are keyed on their hash instead.
+// Requests that are not GraphQL requests, or with POST bodies larger than maxSize bytes, are skipped, see SkipKeyer.
+func (k Keyers) GraphQL(maxSize int64) Keyer {
+	digest := k.hashDigest()
+	return SkipKeyerFunc(func(req *http.Request) (string, bool) {
+		gql, ok := parseGraphQLRequest(req, maxSize)
+		if !ok {
+			return "", false
+		}
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if gql.Query != "" {
+			tokens, err := lexGraphQL(gql.Query)
+			if err != nil {
+				return "", false
+			}
+			buf.WriteString("query:")
+			buf.WriteString(strings.Join(tokens, " "))
+		} else if hash := gql.persistedQueryHash(); hash != "" {
+			buf.WriteString("sha256:")
+			buf.WriteString(hash)
+		} else {
+			return "", false
+		}
+		buf.WriteByte('\n')
+		buf.WriteString(gql.OperationName)
+		buf.WriteByte('\n')
+		if len(gql.Variables) != 0 && !canonicalJSON(buf, gql.Variables) {
+			buf.Write(gql.Variables)
+		}
+		return digest(buf.Bytes()), true
+	})
+}
+
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+	Extensions    struct {
+		PersistedQuery struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+func (g *graphQLRequest) persistedQueryHash() string {
+	return g.Extensions.PersistedQuery.SHA256Hash
+}
+
+func parseGraphQLRequest(req *http.Request, maxSize int64) (*graphQLRequest, bool) {
+	var gql graphQLRequest
+	switch req.Method {
+	case http.MethodGet:
+		query := req.URL.Query()
+		gql.Query = query.Get("query")
+		gql.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			gql.Variables = json.RawMessage(variables)
+		}
+		if extensions := query.Get("extensions"); extensions != "" {
+			if err := json.Unmarshal([]byte(extensions), &gql.Extensions); err != nil {
+				return nil, false
+			}
+		}
+	case http.MethodPost:
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType != "application/json" && mediaType != "application/graphql+json" {
+			return nil, false
+		}
+		body, ok := readBody(req, maxSize)
+		if !ok {
+			return nil, false
+		}
+		if err := json.Unmarshal(body, &gql); err != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	if gql.Query == "" && gql.persistedQueryHash() == "" {
+		return nil, false
+	}
+	return &gql, true
+}
+
+// graphQLOperationType returns the type of the operation that the request executes,
+// the named operation or the only one of the document.
+func graphQLOperationType(tokens []string, operationName string) string {
+	type operation struct {
+		typ, name string
+	}
+	var operations []operation
+	braces, parens := 0, 0
+	definition := true
+	for i, token := range tokens {
+		switch token {
+		case "(":
+			parens++
+		case ")":
+			parens--
+		case "{":
+			if parens != 0 {
+				continue
+			}
+			if definition {
+				operations = append(operations, operation{typ: "query"})
+				definition = false
+			}
+			braces++
+		case "}":
+			if parens != 0 {
+				continue
+			}
+			braces--
+			if braces == 0 {
+				definition = true
+			}
+		default:
+			if !definition {
+				continue
+			}
+			definition = false
+			switch token {
+			case "query", "mutation", "subscription":
+				op := operation{typ: token}
+				if i+1 < len(tokens) && isGraphQLName(tokens[i+1]) {
+					op.name = tokens[i+1]
+				}
+				operations = append(operations, op)
+			}
+		}
+	}
+	if operationName == "" {
+		if len(operations) != 1 {
+			return ""
+		}
+		return operations[0].typ
+	}
+	for _, op := range operations {
+		if op.name == operationName {
+			return op.typ
+		}
+	}
+	return ""
+}
+
+var errGraphQLSyntax = errors.New("malformed GraphQL document")
+
+// lexGraphQL splits a GraphQL document into its tokens,
+// dropping whitespace, commas and comments.
+func lexGraphQL(doc string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(doc[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("!$&()=:@[]{|}", c) != -1:
+			tokens = append(tokens, doc[i:i+1])
+			i++
+		case strings.HasPrefix(doc[i:], `"""`):
+			end := i + 3
+			for {
+				j := strings.Index(doc[end:], `"""`)
+				if j == -1 {
+					return nil, errGraphQLSyntax
+				}
+				end += j + 3
+				if doc[end-4] != '\\' {
+					break
+				}
+			}
+			tokens = append(tokens, doc[i:end])
+			i = end
+		case c == '"':
+			end := i + 1
+			for ; end < len(doc) && doc[end] != '"'; end++ {
+				if doc[end] == '\\' {
+					end++
+				} else if doc[end] == '\n' || doc[end] == '\r' {
+					return nil, errGraphQLSyntax
+				}
+			}
+			if end >= len(doc) {
+				return nil, errGraphQLSyntax
+			}
+			tokens = append(tokens, doc[i:end+1])
+			i = end + 1
+		case isGraphQLNameStart(c):
+			end := i + 1
+			for end < len(doc) && isGraphQLNameContinue(doc[end]) {
+				end++
+			}
+			tokens = append(tokens, doc[i:end])
+			i = end
+		case c == '-' || '0' <= c && c <= '9':
+			end := i + 1
+			for end < len(doc) && strings.IndexByte("0123456789.eE+-", doc[end]) != -1 {
+				end++
+			}
+			tokens = append(tokens, doc[i:end])
+			i = end
+		default:
+			return nil, errGraphQLSyntax
+		}
+	}
+	return tokens, nil
+}
+
+func isGraphQLName(token string) bool {
+	if token == "" || !isGraphQLNameStart(token[0]) {
+		return false
+	}
+	for i := 1; i < len(token); i++ {
+		if !isGraphQLNameContinue(token[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isGraphQLNameContinue(c byte) bool {
+	return isGraphQLNameStart(c) || '0' <= c && c <= '9'
+}
@@ -0,0 +1,130 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newGraphQLPost(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func newGraphQLGet(query url.Values) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "http://example.com/graphql?"+query.Encode(), nil)
+}
+
+func TestGraphQLFilterer(t *testing.T) {
+	filterer := GraphQLFilterer(1 << 20)
+	tests := []struct {
+		name string
+		req  *http.Request
+		want bool
+	}{
+		{
+			name: "shorthand query",
+			req:  newGraphQLPost(`{"query":"{ user(id: 1) { name } }"}`),
+			want: true,
+		},
+		{
+			name: "named query",
+			req:  newGraphQLPost(`{"query":"query User($query: String = \"mutation\") { user(q: $query, f: {a: 1}) { name } }"}`),
+			want: true,
+		},
+		{
+			name: "mutation",
+			req:  newGraphQLPost(`{"query":"mutation { like(id: 1) { count } }"}`),
+			want: false,
+		},
+		{
+			name: "subscription",
+			req:  newGraphQLPost(`{"query":"# comment\nsubscription OnLike { liked { count } }"}`),
+			want: false,
+		},
+		{
+			name: "selected mutation",
+			req:  newGraphQLPost(`{"query":"query A { a } mutation B { b }","operationName":"B"}`),
+			want: false,
+		},
+		{
+			name: "selected query",
+			req:  newGraphQLPost(`{"query":"query A { a } mutation B { b } fragment F on T { f }","operationName":"A"}`),
+			want: true,
+		},
+		{
+			name: "ambiguous operation",
+			req:  newGraphQLPost(`{"query":"query A { a } query B { b }"}`),
+			want: false,
+		},
+		{
+			name: "query via GET",
+			req:  newGraphQLGet(url.Values{"query": {"{ a }"}}),
+			want: true,
+		},
+		{
+			name: "persisted query via GET",
+			req:  newGraphQLGet(url.Values{"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"abc"}}`}}),
+			want: true,
+		},
+		{
+			name: "persisted query via POST",
+			req:  newGraphQLPost(`{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`),
+			want: false,
+		},
+		{
+			name: "not GraphQL",
+			req:  httptest.NewRequest(http.MethodGet, "http://example.com/", nil),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterer.Filter(tt.req); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGraphQLKeyer(t *testing.T) {
+	keyer := GraphQLKeyer(1 << 20)
+	want := keyer.Key(newGraphQLPost(`{"query":"query User($id: ID) { user(id: $id) { name } }","operationName":"User","variables":{"id":1,"lang":"en"}}`))
+	same := []*http.Request{
+		newGraphQLPost(`{"operationName":"User","variables":{"lang":"en","id":1.0},"query":"query User($id: ID) {\n  # the user\n  user(id: $id) {\n    name,\n  }\n}"}`),
+		newGraphQLGet(url.Values{
+			"query":         {"query User($id:ID){user(id:$id){name}}"},
+			"operationName": {"User"},
+			"variables":     {`{"id": 1, "lang": "en"}`},
+		}),
+	}
+	for _, req := range same {
+		if got := keyer.Key(req); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+	different := []*http.Request{
+		newGraphQLPost(`{"query":"query User($id: ID) { user(id: $id) { name } }","operationName":"User","variables":{"id":2,"lang":"en"}}`),
+		newGraphQLPost(`{"query":"query User($id: ID) { user(id: $id) { email } }","operationName":"User","variables":{"id":1,"lang":"en"}}`),
+		newGraphQLPost(`{"query":"query User($id: ID) { user(id: $id) { name } }","variables":{"id":1,"lang":"en"}}`),
+	}
+	for _, req := range different {
+		if got := keyer.Key(req); got == want {
+			t.Errorf("want a different key than %q", want)
+		}
+	}
+
+	persisted := `{"persistedQuery":{"version":1,"sha256Hash":"abc"}}`
+	a := keyer.Key(newGraphQLGet(url.Values{"extensions": {persisted}}))
+	b := keyer.Key(newGraphQLGet(url.Values{"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"def"}}`}}))
+	if a == b || a == "" {
+		t.Errorf("want distinct keys for persisted queries, got %q and %q", a, b)
+	}
+
+	if _, ok := keyer.(SkipKeyer).KeyOrSkip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil)); ok {
+		t.Error("want non GraphQL requests to be skipped")
+	}
+}
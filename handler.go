@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
 )
 
 type Handler struct {
@@ -17,62 +18,43 @@ func NewHandler(base http.Handler, options ...Option) http.Handler {
 	handler := &Handler{
 		Handler: base,
 	}
-	handler.option.init(options)
+	handler.option.init(SharedMode, options)
 	return handler
 }
 
-func (h *Handler) unmarshalResponse(rw http.ResponseWriter, r io.Reader) error {
-	resp, err := unmarshalResponse(r)
-	if err != nil {
-		return err
-	}
-	header := rw.Header()
-	for key, values := range resp.Header {
-		header[key] = values
-	}
-	rw.WriteHeader(resp.StatusCode)
-	buf := getBytes()
-	defer putBytes(buf)
-	_, err = io.CopyBuffer(rw, resp.Body, buf)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	if !h.filterer.Filter(r) {
+	if h.purgeAuth != nil && (r.Method == MethodPurge || r.Method == MethodBan) {
+		h.serveInvalidation(rw, r)
+		return
+	}
+	key, ok := h.key(r)
+	if !ok {
 		h.Handler.ServeHTTP(rw, r)
 		return
 	}
-	key := h.keyer.Key(r)
 
-	data, ok := h.storer.Get(key)
-	if ok {
-		err := h.unmarshalResponse(rw, data)
-		if err == nil {
-			data.Close()
-			return
-		}
-		data.Close()
+	resp, result := h.load(r, key, entryMeta.fresh)
+	if resp != nil {
+		h.hit(r, key, resp, result)
+		serveResponse(rw, resp)
+		return
 	}
 
 	var mutex sync.RWMutex
 	mut, ok := h.muts.LoadOrStore(key, &mutex)
 	rmut := mut.(*sync.RWMutex)
 	if ok {
-		rmut.RLock()
+		h.wait(r, key, rmut)
 		defer rmut.RUnlock()
-		data, ok := h.storer.Get(key)
-		if ok {
-			err := h.unmarshalResponse(rw, data)
-			if err == nil {
-				data.Close()
-				return
-			}
-			data.Close()
+		resp, result := h.load(r, key, entryMeta.fresh)
+		if resp != nil {
+			h.hit(r, key, resp, result)
+			serveResponse(rw, resp)
+			return
 		}
-		h.Handler.ServeHTTP(rw, r)
+		fetchReq, span := h.fetch(r, key, result)
+		defer span.End()
+		h.Handler.ServeHTTP(rw, fetchReq)
 		return
 	}
 
@@ -82,20 +64,24 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		h.muts.Delete(key)
 	}()
 
+	fetchReq, span := h.fetch(r, key, result)
+	start := time.Now()
 	w := newResponseWriter(rw)
 
-	h.Handler.ServeHTTP(w, r)
-	if h.discarder.Discard(w) {
+	h.Handler.ServeHTTP(w, fetchReq)
+	span.SetAttribute(AttributeStatusCode, int64(w.StatusCode()))
+	span.SetAttribute(AttributeBytes, int64(w.buf.Len()))
+	span.End()
+	if !h.admitHeader(r, w) {
+		h.observe(r, Event{Kind: EventDiscard, Key: key})
 		return
 	}
-
-	if buf, ok := h.storer.Put(key); ok {
-		err := marshalResponse(&w.response, buf)
-		buf.Close()
-		if err != nil {
-			h.storer.Del(key)
-		}
+	storeKey, meta, ok := h.admit(r, w, w.buf.Bytes(), key)
+	if !ok {
+		h.observe(r, Event{Kind: EventDiscard, Key: key})
+		return
 	}
+	h.store(r, storeKey, &w.response, w.buf.Bytes(), meta, start)
 }
 
 type responseWriter struct {
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -65,21 +67,26 @@ func TestParallelHandler(t *testing.T) {
 			defer wg.Done()
 			resp, err := cli.Get(server.URL + "/handler")
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			body, err := io.ReadAll(resp.Body)
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			resp.Body.Close()
 			if resp.StatusCode != http.StatusAccepted {
-				t.Fatalf("want %q, got %q", http.StatusAccepted, resp.StatusCode)
+				t.Errorf("want %q, got %q", http.StatusAccepted, resp.StatusCode)
+				return
 			}
 			if got := resp.Header.Get("want"); !reflect.DeepEqual(want, got) {
-				t.Fatalf("want %q, got %q", want, got)
+				t.Errorf("want %q, got %q", want, got)
+				return
 			}
 			if !reflect.DeepEqual(want, string(body)) {
-				t.Fatalf("want %q, got %q", want, body)
+				t.Errorf("want %q, got %q", want, body)
+				return
 			}
 		}()
 	}
@@ -90,6 +97,97 @@ func TestParallelHandler(t *testing.T) {
 	}
 }
 
+func TestVaryHandler(t *testing.T) {
+	var count int64
+	storer := MemoryStorer()
+	handler := NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		rw.Header().Set("Vary", "Accept-Language")
+		rw.Write([]byte(r.Header.Get("Accept-Language")))
+	}),
+		WithStorer(storer),
+		WithKeyer(VaryKeyer(JointKeyer(HostKeyer(), PathKeyer()), storer)),
+	)
+
+	for _, lang := range []string{"en", "en", "fr", "fr", "en"} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/vary", nil)
+		req.Header.Set("Accept-Language", lang)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if got := rw.Body.String(); got != lang {
+			t.Fatalf("want %q, got %q", lang, got)
+		}
+	}
+
+	if count != 2 {
+		t.Fatalf("want 2 origin requests, got %d", count)
+	}
+}
+
+func TestVaryHandlerBodyKey(t *testing.T) {
+	var count int64
+	storer := MemoryStorer()
+	handler := NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		body, _ := io.ReadAll(r.Body)
+		rw.Header().Set("Vary", "Accept-Language")
+		rw.Write(body)
+	}),
+		WithStorer(storer),
+		WithFilterer(MethodFilterer(http.MethodPost)),
+		WithKeyer(VaryKeyer(JSONBodyKeyer(1024), storer)),
+	)
+
+	for _, body := range []string{`{"a":1}`, `{"a":1}`, `{"a":2}`, `{"a":1}`, ``} {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(body))
+		req.Header.Set("Accept-Language", "en")
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if got := rw.Body.String(); got != body {
+			t.Fatalf("want %q, got %q", body, got)
+		}
+	}
+
+	if count != 3 {
+		t.Fatalf("want 3 origin requests, got %d", count)
+	}
+}
+
+func TestVaryKeyerBoundedNames(t *testing.T) {
+	storer := &countingStorer{Storer: MemoryStorer()}
+	keyer := VaryKeyer(PathKeyer(), storer).(*varyKeyer)
+	for i := 0; i != 2; i++ {
+		keyer.Key(httptest.NewRequest(http.MethodGet, "/miss", nil))
+	}
+	if storer.gets != 1 {
+		t.Fatalf("want the missing record to be looked up once, got %d", storer.gets)
+	}
+	for i := 0; i != maxVaryNames*2; i++ {
+		keyer.Key(httptest.NewRequest(http.MethodGet, "/miss/"+strconv.Itoa(i), nil))
+	}
+	if n := len(keyer.names); n != maxVaryNames {
+		t.Fatalf("want %d lists, got %d", maxVaryNames, n)
+	}
+	for i := 0; i != maxVaryNames*2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/hit/"+strconv.Itoa(i), nil)
+		keyer.VaryKey(req, keyer.Key(req), newTestResponse(http.StatusOK, "Vary", "Accept"))
+	}
+	if n := len(keyer.names); n != maxVaryNames {
+		t.Fatalf("want %d lists, got %d", maxVaryNames, n)
+	}
+}
+
+// countingStorer counts the lookups of the storer.
+type countingStorer struct {
+	Storer
+	gets int
+}
+
+func (s *countingStorer) Get(key string) (io.ReadCloser, bool) {
+	s.gets++
+	return s.Storer.Get(key)
+}
+
 func BenchmarkCacheMemoryHandler(b *testing.B) {
 	want := "OK"
 	server := httptest.NewTLSServer(NewHandler(
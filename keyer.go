@@ -2,12 +2,11 @@ package httpcache
 
 import (
 	"bytes"
-	"crypto/md5"
-	"encoding/base64"
-	"encoding/hex"
+	"context"
 	"io"
 	"net/http"
 	"path"
+	"strings"
 )
 
 func JointKeyer(keyers ...Keyer) Keyer {
@@ -17,48 +16,103 @@ func JointKeyer(keyers ...Keyer) Keyer {
 	case 1:
 		return keyers[0]
 	}
-	return KeyerFunc(func(req *http.Request) string {
+	return SkipKeyerFunc(func(req *http.Request) (string, bool) {
 		keys := make([]string, 0, len(keyers))
 		for _, keyer := range keyers {
-			key := keyer.Key(req)
+			key, ok := keyOrSkip(keyer, req)
+			if !ok {
+				return "", false
+			}
 			if key == "" {
 				key = emptyKey
 			}
 			keys = append(keys, key)
 		}
-		return path.Join(keys...)
+		return path.Join(keys...), true
 	})
 }
 
-func hashKeyer(f func(buf *bytes.Buffer, req *http.Request) []byte) Keyer {
+func digestKeyer(digest Digest, f func(buf *bytes.Buffer, req *http.Request) []byte) Keyer {
 	return KeyerFunc(func(req *http.Request) string {
 		buf := getBuffer()
 		defer putBuffer(buf)
-		hash := md5.New()
-		hash.Write(f(buf, req))
-		var tmp [md5.Size]byte
-		return hex.EncodeToString(hash.Sum(tmp[:0]))
+		return digest(f(buf, req))
 	})
 }
 
-func base64Keyer(f func(buf *bytes.Buffer, req *http.Request) []byte) Keyer {
-	return KeyerFunc(func(req *http.Request) string {
-		buf := getBuffer()
-		defer putBuffer(buf)
-		return base64.RawStdEncoding.EncodeToString(f(buf, req))
-	})
+func BodyKeyer() Keyer {
+	return defaultKeyers.Body()
 }
 
-func BodyKeyer() Keyer {
-	return hashKeyer(func(buf *bytes.Buffer, req *http.Request) []byte {
+func QueryKeyer(names ...string) Keyer {
+	return defaultKeyers.Query(names...)
+}
+
+func HeaderKeyer(names ...string) Keyer {
+	return defaultKeyers.Header(names...)
+}
+
+func CookieKeyer(names ...string) Keyer {
+	return defaultKeyers.Cookie(names...)
+}
+
+func PathKeyer() Keyer {
+	return defaultKeyers.Path()
+}
+
+func HostKeyer() Keyer {
+	return defaultKeyers.Host()
+}
+
+func MethodKeyer() Keyer {
+	return defaultKeyers.Method()
+}
+
+// Keyers builds the built-in keyers with the same Digest applied to every component.
+type Keyers struct {
+	digest Digest
+}
+
+// DigestKeyers returns the built-in keyers using the digest.
+// The zero Keyers, used by the package level keyers, hashes bodies with MD5,
+// encodes other components with base64 and keeps the host as is.
+func DigestKeyers(digest Digest) Keyers {
+	return Keyers{
+		digest: digest,
+	}
+}
+
+var defaultKeyers = Keyers{}
+
+func (k Keyers) hashDigest() Digest {
+	if k.digest == nil {
+		return MD5Digest
+	}
+	return k.digest
+}
+
+func (k Keyers) hashed(f func(buf *bytes.Buffer, req *http.Request) []byte) Keyer {
+	return digestKeyer(k.hashDigest(), f)
+}
+
+func (k Keyers) encoded(f func(buf *bytes.Buffer, req *http.Request) []byte) Keyer {
+	digest := k.digest
+	if digest == nil {
+		digest = Base64Digest
+	}
+	return digestKeyer(digest, f)
+}
+
+func (k Keyers) Body() Keyer {
+	return k.hashed(func(buf *bytes.Buffer, req *http.Request) []byte {
 		body, _ := io.ReadAll(req.Body)
 		req.Body = io.NopCloser(bytes.NewBuffer(body))
 		return body
 	})
 }
 
-func QueryKeyer(names ...string) Keyer {
-	return base64Keyer(func(buf *bytes.Buffer, req *http.Request) []byte {
+func (k Keyers) Query(names ...string) Keyer {
+	return k.encoded(func(buf *bytes.Buffer, req *http.Request) []byte {
 		query := req.URL.Query()
 		for _, name := range names {
 			values, ok := query[name]
@@ -81,8 +135,8 @@ func QueryKeyer(names ...string) Keyer {
 	})
 }
 
-func HeaderKeyer(names ...string) Keyer {
-	return base64Keyer(func(buf *bytes.Buffer, req *http.Request) []byte {
+func (k Keyers) Header(names ...string) Keyer {
+	return k.encoded(func(buf *bytes.Buffer, req *http.Request) []byte {
 		query := req.Header
 		for _, name := range names {
 			values, ok := query[name]
@@ -105,8 +159,27 @@ func HeaderKeyer(names ...string) Keyer {
 	})
 }
 
-func PathKeyer() Keyer {
-	return base64Keyer(func(buf *bytes.Buffer, req *http.Request) []byte {
+// Cookie keys on the values of the named cookies of the Cookie headers.
+func (k Keyers) Cookie(names ...string) Keyer {
+	return k.encoded(func(buf *bytes.Buffer, req *http.Request) []byte {
+		cookies := req.Cookies()
+		for _, name := range names {
+			for _, cookie := range cookies {
+				if cookie.Name != name {
+					continue
+				}
+				buf.WriteString(name)
+				buf.WriteByte('=')
+				buf.WriteString(cookie.Value)
+				buf.WriteByte('\n')
+			}
+		}
+		return buf.Bytes()
+	})
+}
+
+func (k Keyers) Path() Keyer {
+	return k.encoded(func(buf *bytes.Buffer, req *http.Request) []byte {
 		if req.URL.Path == "" {
 			return []byte{'/'}
 		}
@@ -114,26 +187,94 @@ func PathKeyer() Keyer {
 	})
 }
 
-func HostKeyer() Keyer {
+func (k Keyers) Host() Keyer {
+	if k.digest == nil {
+		return KeyerFunc(requestHost)
+	}
 	return KeyerFunc(func(req *http.Request) string {
-		if req.Host != "" {
-			return req.Host
-		}
-		if req.URL.Host != "" {
-			return req.URL.Host
+		host := requestHost(req)
+		if host == "" {
+			return ""
 		}
-		return ""
+		return k.digest([]byte(host))
 	})
 }
 
-func MethodKeyer() Keyer {
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	if req.URL.Host != "" {
+		return req.URL.Host
+	}
+	return ""
+}
+
+// Method keys on the request method as is, methods are short tokens that are always safe in keys.
+func (k Keyers) Method() Keyer {
 	return KeyerFunc(func(req *http.Request) string {
 		return req.Method
 	})
 }
 
+// LimitKeyer replaces every slash separated component of the keys longer than n bytes with its digest,
+// keeping keys within file name limits of storers such as Directory.
+// Digests longer than n bytes are truncated to n bytes, so the digest should be a hash such as SHA256Digest.
+func LimitKeyer(n int, digest Digest, keyer Keyer) Keyer {
+	return SkipKeyerFunc(func(req *http.Request) (string, bool) {
+		key, ok := keyOrSkip(keyer, req)
+		if !ok || len(key) <= n {
+			return key, ok
+		}
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			if len(segment) > n {
+				segments[i] = digest([]byte(segment))
+				if len(segments[i]) > n {
+					segments[i] = segments[i][:n]
+				}
+			}
+		}
+		return strings.Join(segments, "/"), true
+	})
+}
+
+// keyOrSkip returns the key of the request, or false if the keyer is a SkipKeyer skipping it.
+func keyOrSkip(keyer Keyer, req *http.Request) (string, bool) {
+	if skipKeyer, ok := keyer.(SkipKeyer); ok {
+		return skipKeyer.KeyOrSkip(req)
+	}
+	return keyer.Key(req), true
+}
+
+type skipContextKey struct{}
+
+// skipRequest makes the request bypass the cache if it is keyed by keyRequest.
+func skipRequest(req *http.Request) {
+	if skipped, ok := req.Context().Value(skipContextKey{}).(*bool); ok {
+		*skipped = true
+	}
+}
+
+// keyRequest returns the key of the request, or false if the request must bypass the cache,
+// including when a SkipKeyer wrapped by a keyer that is not one skips it.
+func keyRequest(keyer Keyer, req *http.Request) (string, bool) {
+	skipped := false
+	keyed := req.WithContext(context.WithValue(req.Context(), skipContextKey{}, &skipped))
+	key, ok := keyOrSkip(keyer, keyed)
+	// Keyers reading the body leave a new one in its place to be sent upstream.
+	req.Body = keyed.Body
+	return key, ok && !skipped
+}
+
 func noKey(req *http.Request) string {
 	return "empty"
 }
 
 const emptyKey = "EMPTY"
+
+// isRecordKey reports whether the key is one that keyers store their records under,
+// such as the Vary lists of VaryKeyer and the generation of GenerationKeyer, rather than a cached response.
+func isRecordKey(key string) bool {
+	return strings.HasSuffix(key, varySuffix) || path.Base(key) == generationRecord
+}
@@ -0,0 +1,167 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONBodyKeyer keys on the canonical form of a JSON request body, see Keyers.JSONBody.
+func JSONBodyKeyer(maxSize int64) Keyer {
+	return defaultKeyers.JSONBody(maxSize)
+}
+
+// FormBodyKeyer keys on the canonical form of a URL-encoded form request body, see Keyers.FormBody.
+func FormBodyKeyer(maxSize int64) Keyer {
+	return defaultKeyers.FormBody(maxSize)
+}
+
+// JSONBody keys on the request body as JSON with sorted object keys, no insignificant whitespace
+// and numbers compared by value, so 1, 1.0 and 1e0 are the same.
+// Bodies that are not valid JSON are keyed on their raw bytes.
+// Requests with bodies larger than maxSize bytes are skipped and bypass the cache, see SkipKeyer.
+func (k Keyers) JSONBody(maxSize int64) Keyer {
+	return k.body(maxSize, func(buf *bytes.Buffer, body []byte) []byte {
+		if !canonicalJSON(buf, body) {
+			return body
+		}
+		return buf.Bytes()
+	})
+}
+
+// FormBody keys on the application/x-www-form-urlencoded request body with its fields sorted by name and value.
+// Requests with bodies larger than maxSize bytes are skipped and bypass the cache, see SkipKeyer.
+func (k Keyers) FormBody(maxSize int64) Keyer {
+	return k.body(maxSize, func(buf *bytes.Buffer, body []byte) []byte {
+		buf.WriteString(canonicalQuery(string(body), nil))
+		return buf.Bytes()
+	})
+}
+
+func (k Keyers) body(maxSize int64, f func(buf *bytes.Buffer, body []byte) []byte) Keyer {
+	digest := k.hashDigest()
+	return SkipKeyerFunc(func(req *http.Request) (string, bool) {
+		body, ok := readBody(req, maxSize)
+		if !ok {
+			return "", false
+		}
+		buf := getBuffer()
+		defer putBuffer(buf)
+		return digest(f(buf, body)), true
+	})
+}
+
+// readBody reads the request body if it is no larger than maxSize bytes,
+// the body is restored so that it can still be sent upstream.
+func readBody(req *http.Request, maxSize int64) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil || int64(len(body)) > maxSize {
+		req.Body = &readerWithClose{
+			Reader: io.MultiReader(bytes.NewReader(body), req.Body),
+			close:  req.Body.Close,
+		}
+		return nil, false
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func canonicalJSON(buf *bytes.Buffer, data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return false
+	}
+	writeCanonicalJSON(buf, v)
+	return true
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalJSON(buf, key)
+			buf.WriteByte(':')
+			writeCanonicalJSON(buf, v[key])
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalJSON(buf, elem)
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		buf.WriteString(canonicalNumber(string(v)))
+	default:
+		data, _ := json.Marshal(v)
+		buf.Write(data)
+	}
+}
+
+// canonicalNumber writes the JSON number as its significant digits and a power of ten,
+// so that equal numbers such as 1, 1.0 and 10e-1 are written the same,
+// without ever expanding the exponent.
+func canonicalNumber(number string) string {
+	s := number
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		mantissa, exponent = s[:i], s[i+1:]
+	}
+	exp := int64(0)
+	if exponent != "" {
+		e, err := strconv.ParseInt(exponent, 10, 64)
+		if err != nil || e > maxCanonicalExponent || e < -maxCanonicalExponent {
+			return number
+		}
+		exp = e
+	}
+	digits := mantissa
+	if i := strings.IndexByte(mantissa, '.'); i != -1 {
+		digits = mantissa[:i] + mantissa[i+1:]
+		exp -= int64(len(mantissa) - i - 1)
+	}
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += int64(len(digits) - len(trimmed))
+	if neg {
+		trimmed = "-" + trimmed
+	}
+	if exp == 0 {
+		return trimmed
+	}
+	return trimmed + "e" + strconv.FormatInt(exp, 10)
+}
+
+// maxCanonicalExponent keeps the exponent arithmetic of canonicalNumber away from overflows,
+// numbers with larger exponents are written as they are.
+const maxCanonicalExponent = 1 << 40
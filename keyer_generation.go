@@ -0,0 +1,226 @@
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// GenerationKeyer prefixes the keys of a keyer with a namespace and a generation number,
+// bumping the generation makes every entry of the previous generations unreachable at once.
+type GenerationKeyer struct {
+	generation uint64
+
+	namespace string
+	keyer     Keyer
+	storer    Storer
+	observer  Observer
+	mut       sync.Mutex
+}
+
+type GenerationKeyerOption func(g *GenerationKeyer)
+
+// WithSweepObserver sets the Observer notified of the entries deleted by Sweep as EventEviction.
+func WithSweepObserver(observer Observer) GenerationKeyerOption {
+	return func(g *GenerationKeyer) {
+		g.observer = observer
+	}
+}
+
+// NewGenerationKeyer returns a GenerationKeyer whose generation is persisted in the storer,
+// which is also the storer swept by Sweep. The storer may be nil to keep the generation in memory only.
+func NewGenerationKeyer(namespace string, keyer Keyer, storer Storer, options ...GenerationKeyerOption) *GenerationKeyer {
+	g := &GenerationKeyer{
+		namespace: namespace,
+		keyer:     keyer,
+		storer:    storer,
+	}
+	for _, option := range options {
+		option(g)
+	}
+	g.generation = g.load()
+	return g
+}
+
+func (g *GenerationKeyer) Key(req *http.Request) string {
+	return SkipKeyerFunc(g.KeyOrSkip).Key(req)
+}
+
+func (g *GenerationKeyer) KeyOrSkip(req *http.Request) (string, bool) {
+	key, ok := keyOrSkip(g.keyer, req)
+	if !ok {
+		return "", false
+	}
+	if key == "" {
+		key = emptyKey
+	}
+	return path.Join(g.namespace, generationSegment(g.Generation()), key), true
+}
+
+// VaryKey forwards to the keyer if it is a Varier, keeping the namespace and the generation of the key.
+func (g *GenerationKeyer) VaryKey(req *http.Request, key string, resp Response) (string, bool) {
+	varier, ok := g.keyer.(Varier)
+	if !ok {
+		return key, true
+	}
+	prefix, inner, ok := g.splitKey(key)
+	if !ok {
+		return "", false
+	}
+	inner, ok = varier.VaryKey(req, inner, resp)
+	if !ok {
+		return "", false
+	}
+	return prefix + inner, true
+}
+
+func (g *GenerationKeyer) variantPrefix(key string) (string, bool) {
+	prefix, inner, ok := g.splitKey(key)
+	if !ok {
+		return "", false
+	}
+	inner, ok = variantPrefix(g.keyer, inner)
+	if !ok {
+		return "", false
+	}
+	return prefix + inner, true
+}
+
+// splitKey splits the key into the namespace and generation prefix and the key of the keyer.
+func (g *GenerationKeyer) splitKey(key string) (string, string, bool) {
+	prefix := g.namespacePrefix()
+	if !strings.HasPrefix(key, prefix) {
+		return "", "", false
+	}
+	i := strings.IndexByte(key[len(prefix):], '/')
+	if i == -1 {
+		return "", "", false
+	}
+	i += len(prefix) + 1
+	return key[:i], key[i:], true
+}
+
+// namespacePrefix is the prefix of the keys of the namespace, followed by the generation.
+func (g *GenerationKeyer) namespacePrefix() string {
+	if g.namespace == "" {
+		return ""
+	}
+	return path.Clean(g.namespace) + "/"
+}
+
+// Generation returns the current generation.
+func (g *GenerationKeyer) Generation() uint64 {
+	return atomic.LoadUint64(&g.generation)
+}
+
+// Bump moves to a new generation and persists it.
+func (g *GenerationKeyer) Bump() (uint64, error) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	generation := atomic.AddUint64(&g.generation, 1)
+	return generation, g.store(generation)
+}
+
+// Sweep deletes the entries of every other generation of the namespace from the storer,
+// the storer must implement Lister. It returns the number of deleted entries.
+// The namespace must not be empty, as the entries of other keyers cannot be told apart from other generations.
+func (g *GenerationKeyer) Sweep() (int, error) {
+	if g.namespace == "" {
+		return 0, errSweepNamespace
+	}
+	lister, ok := g.storer.(Lister)
+	if !ok {
+		return 0, errNotLister
+	}
+	prefix := g.namespacePrefix()
+	current := generationSegment(g.Generation())
+	var keys []string
+	err := lister.List(prefix, "", 0, func(key string, size int64) error {
+		segment := strings.TrimPrefix(key, prefix)
+		i := strings.IndexByte(segment, '/')
+		if i == -1 {
+			return nil
+		}
+		segment = segment[:i]
+		if segment == current || !isGenerationSegment(segment) {
+			return nil
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	deleted := 0
+	for _, key := range keys {
+		if g.storer.Del(key) {
+			if g.observer != nil {
+				g.observer.Observe(Event{Kind: EventEviction, Key: key})
+			}
+			deleted++
+		}
+	}
+	return deleted, err
+}
+
+var (
+	errNotLister      = errors.New("storer does not support listing")
+	errSweepNamespace = errors.New("cannot sweep the generations of an empty namespace")
+)
+
+// generationRecord names the record of the current generation in the namespace.
+const generationRecord = "generation"
+
+func (g *GenerationKeyer) recordKey() string {
+	return path.Join(g.namespace, generationRecord)
+}
+
+func (g *GenerationKeyer) load() uint64 {
+	if g.storer == nil {
+		return 0
+	}
+	r, ok := g.storer.Get(g.recordKey())
+	if !ok {
+		return 0
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0
+	}
+	generation, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return generation
+}
+
+func (g *GenerationKeyer) store(generation uint64) error {
+	if g.storer == nil {
+		return nil
+	}
+	w, ok := g.storer.Put(g.recordKey())
+	if !ok {
+		return errors.New("failed to store the generation")
+	}
+	_, err := io.WriteString(w, strconv.FormatUint(generation, 10))
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func generationSegment(generation uint64) string {
+	return "g" + strconv.FormatUint(generation, 10)
+}
+
+func isGenerationSegment(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'g' {
+		return false
+	}
+	_, err := strconv.ParseUint(segment[1:], 10, 64)
+	return err == nil
+}
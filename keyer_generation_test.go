@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerationKeyer(t *testing.T) {
+	tests := []struct {
+		name   string
+		storer Storer
+	}{
+		{
+			name:   "MemoryStorer",
+			storer: MemoryStorer(),
+		},
+		{
+			name:   "DirectoryStorer",
+			storer: DirectoryStorer(t.TempDir()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/a", nil)
+			keyer := NewGenerationKeyer("site", JointKeyer(HostKeyer(), PathKeyer()), tt.storer)
+			put := func(key string) {
+				w, ok := tt.storer.Put(key)
+				if !ok {
+					t.Fatalf("expected to get the writer for %q", key)
+				}
+				w.Write([]byte("data"))
+				w.Close()
+			}
+
+			old := keyer.Key(req)
+			if want := "site/g0/example.com/" + PathKeyer().Key(req); old != want {
+				t.Fatalf("want %q, got %q", want, old)
+			}
+			put(old)
+			put("other/g0/example.com/x")
+
+			generation, err := keyer.Bump()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if generation != 1 {
+				t.Fatalf("want generation 1, got %d", generation)
+			}
+			current := keyer.Key(req)
+			if current == old {
+				t.Fatalf("want a new key, got %q", current)
+			}
+			put(current)
+
+			reloaded := NewGenerationKeyer("site", JointKeyer(HostKeyer(), PathKeyer()), tt.storer)
+			if got := reloaded.Key(req); got != current {
+				t.Fatalf("want persisted key %q, got %q", current, got)
+			}
+
+			deleted, err := keyer.Sweep()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != 1 {
+				t.Fatalf("want 1 deleted entry, got %d", deleted)
+			}
+			for key, want := range map[string]bool{
+				old:                      false,
+				current:                  true,
+				"other/g0/example.com/x": true,
+				"site/generation":        true,
+			} {
+				r, ok := tt.storer.Get(key)
+				if ok {
+					r.Close()
+				}
+				if ok != want {
+					t.Errorf("%q: want available %v, got %v", key, want, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerationKeyerVary(t *testing.T) {
+	var count int64
+	storer := MemoryStorer()
+	keyer := NewGenerationKeyer("site", VaryKeyer(JointKeyer(HostKeyer(), PathKeyer()), storer), storer)
+	handler := NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		count++
+		rw.Header().Set("Vary", "Accept-Encoding")
+		rw.Write([]byte(r.Header.Get("Accept-Encoding")))
+	}), WithStorer(storer), WithKeyer(keyer))
+
+	for _, encoding := range []string{"gzip", "br", "gzip", "br"} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/a", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if got := rw.Body.String(); got != encoding {
+			t.Fatalf("want %q, got %q", encoding, got)
+		}
+	}
+	if count != 2 {
+		t.Fatalf("want 2 origin requests, got %d", count)
+	}
+}
+
+func TestGenerationKeyerSweepEmptyNamespace(t *testing.T) {
+	storer := MemoryStorer()
+	w, _ := storer.Put("g0/example.com/a")
+	w.Close()
+	keyer := NewGenerationKeyer("", PathKeyer(), storer)
+	keyer.Bump()
+	if _, err := keyer.Sweep(); err == nil {
+		t.Fatal("want an error")
+	}
+	if _, ok := storer.Get("g0/example.com/a"); !ok {
+		t.Fatal("want the entry to be kept")
+	}
+}
@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBodySize is the body size limit of placeholders keying on request bodies.
+const defaultMaxBodySize = 1 << 20
+
+// ParseKeyer builds a keyer from a template such as
+//
+//	{method}:{host}{path}?{query:a,b}#{header:X-Tenant}{cookie:tier}
+//
+// where every placeholder is replaced with the key of the built-in keyer of the same name,
+// and the rest is kept literally, "{{" and "}}" stand for literal braces.
+// Like JointKeyer, empty components are replaced and the resulting key is cleaned as a slash separated path,
+// so "{host}/{path}" keys the same as JointKeyer(HostKeyer(), PathKeyer()).
+//
+// The placeholders are:
+//
+//	{method}                 MethodKeyer()
+//	{host}                   HostKeyer()
+//	{path}                   PathKeyer()
+//	{url} {url:utm_*,...}    URLKeyer(ignore...)
+//	{query:name,...}         QueryKeyer(names...)
+//	{header:name,...}        HeaderKeyer(names...)
+//	{cookie:name,...}        CookieKeyer(names...)
+//	{body}                   BodyKeyer()
+//	{json} {json:size}       JSONBodyKeyer(size), 1MiB by default
+//	{form} {form:size}       FormBodyKeyer(size), 1MiB by default
+//	{graphql} {graphql:size} GraphQLKeyer(size), 1MiB by default
+func ParseKeyer(template string) (Keyer, error) {
+	return defaultKeyers.Parse(template)
+}
+
+// Parse builds a keyer from a template like ParseKeyer, with the placeholders replaced by the keyers of k.
+func (k Keyers) Parse(template string) (Keyer, error) {
+	var parts []templatePart
+	var literal strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '{' && strings.HasPrefix(template[i:], "{{"):
+			literal.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(template[i:], "}}"):
+			literal.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("unexpected '}' at offset %d in key template %q", i, template)
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated placeholder at offset %d in key template %q", i, template)
+			}
+			keyer, err := k.parsePlaceholder(template[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid placeholder at offset %d in key template %q: %w", i, template, err)
+			}
+			if literal.Len() != 0 {
+				parts = append(parts, templatePart{literal: literal.String()})
+				literal.Reset()
+			}
+			parts = append(parts, templatePart{keyer: keyer})
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() != 0 {
+		parts = append(parts, templatePart{literal: literal.String()})
+	}
+	switch len(parts) {
+	case 0:
+		return nil, fmt.Errorf("empty key template")
+	case 1:
+		if parts[0].keyer != nil {
+			return parts[0].keyer, nil
+		}
+	}
+	return templateKeyer(parts), nil
+}
+
+type templatePart struct {
+	literal string
+	keyer   Keyer
+}
+
+type templateKeyer []templatePart
+
+func (t templateKeyer) Key(req *http.Request) string {
+	return SkipKeyerFunc(t.KeyOrSkip).Key(req)
+}
+
+func (t templateKeyer) KeyOrSkip(req *http.Request) (string, bool) {
+	var b strings.Builder
+	for _, part := range t {
+		if part.keyer == nil {
+			b.WriteString(part.literal)
+			continue
+		}
+		key, ok := keyOrSkip(part.keyer, req)
+		if !ok {
+			return "", false
+		}
+		if key == "" {
+			key = emptyKey
+		}
+		b.WriteString(key)
+	}
+	return path.Clean(b.String()), true
+}
+
+func (k Keyers) parsePlaceholder(placeholder string) (Keyer, error) {
+	name, arg, hasArg := strings.Cut(placeholder, ":")
+	var args []string
+	if hasArg {
+		args = strings.Split(arg, ",")
+		for i, arg := range args {
+			args[i] = strings.TrimSpace(arg)
+			if args[i] == "" {
+				return nil, fmt.Errorf("empty argument of placeholder %q", name)
+			}
+		}
+	}
+	switch name {
+	case "method", "host", "path", "body":
+		if hasArg {
+			return nil, fmt.Errorf("placeholder %q takes no arguments", name)
+		}
+		switch name {
+		case "method":
+			return k.Method(), nil
+		case "host":
+			return k.Host(), nil
+		case "path":
+			return k.Path(), nil
+		default:
+			return k.Body(), nil
+		}
+	case "url":
+		return k.URL(args...), nil
+	case "query", "header", "cookie":
+		if !hasArg {
+			return nil, fmt.Errorf("placeholder %q requires names", name)
+		}
+		switch name {
+		case "query":
+			return k.Query(args...), nil
+		case "header":
+			return k.Header(args...), nil
+		default:
+			return k.Cookie(args...), nil
+		}
+	case "json", "form", "graphql":
+		size := int64(defaultMaxBodySize)
+		if hasArg {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("placeholder %q takes a single size", name)
+			}
+			n, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid size %q of placeholder %q", args[0], name)
+			}
+			size = n
+		}
+		switch name {
+		case "json":
+			return k.JSONBody(size), nil
+		case "form":
+			return k.FormBody(size), nil
+		default:
+			return k.GraphQL(size), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown placeholder %q", name)
+}
@@ -0,0 +1,78 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyer(t *testing.T) {
+	newRequests := func() []*http.Request {
+		plain := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		full := httptest.NewRequest(http.MethodPost, "http://example.com:8080/a/b?a=1&b=2&c=3&utm_source=x", strings.NewReader(`{"b":1,"a":2}`))
+		full.Header.Set("X-Tenant", "acme")
+		full.Header.Set("Cookie", "tier=gold; session=1")
+		return []*http.Request{plain, full}
+	}
+	tests := []struct {
+		template string
+		want     Keyer
+	}{
+		{"{host}", HostKeyer()},
+		{"{host}/{path}", JointKeyer(HostKeyer(), PathKeyer())},
+		{"{method}/{host}/{path}/{query:a,b}", JointKeyer(MethodKeyer(), HostKeyer(), PathKeyer(), QueryKeyer("a", "b"))},
+		{"{header:X-Tenant}/{cookie:tier}", JointKeyer(HeaderKeyer("X-Tenant"), CookieKeyer("tier"))},
+		{"{url:utm_*}", URLKeyer("utm_*")},
+		{"{host}/{body}", JointKeyer(HostKeyer(), BodyKeyer())},
+		{"{host}/{json:64}", JointKeyer(HostKeyer(), JSONBodyKeyer(64))},
+		{"{host}/{form}", JointKeyer(HostKeyer(), FormBodyKeyer(defaultMaxBodySize))},
+		{"{graphql}", GraphQLKeyer(defaultMaxBodySize)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			keyer, err := ParseKeyer(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, req := range newRequests() {
+				got := keyer.Key(req)
+				want := tt.want.Key(req)
+				if got != want {
+					t.Errorf("%s %s: want %q, got %q", req.Method, req.URL, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseKeyerLiterals(t *testing.T) {
+	keyer, err := ParseKeyer("{method}:{host}{path}?{query:a,b}#{header:X-Tenant}{cookie:tier}{{x}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/p?b=2&a=1", nil)
+	want := "GET:example.com" + PathKeyer().Key(req) + "?" + QueryKeyer("a", "b").Key(req) + "#" + emptyKey + emptyKey + "{x}"
+	if got := keyer.Key(req); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestParseKeyerErrors(t *testing.T) {
+	for _, template := range []string{
+		"",
+		"{unknown}",
+		"{host",
+		"host}",
+		"{query}",
+		"{header:}",
+		"{cookie:a,,b}",
+		"{path:x}",
+		"{json:big}",
+		"{json:0}",
+	} {
+		if _, err := ParseKeyer(template); err == nil {
+			t.Errorf("%q: expected an error", template)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestKeyers(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/a/b?q=1", strings.NewReader("body"))
+	tests := []struct {
+		name   string
+		keyers Keyers
+		size   int
+	}{
+		{
+			name:   "SHA256",
+			keyers: DigestKeyers(SHA256Digest),
+			size:   64,
+		},
+		{
+			name:   "MD5",
+			keyers: DigestKeyers(MD5Digest),
+			size:   32,
+		},
+		{
+			name:   "FNV",
+			keyers: DigestKeyers(FNVDigest),
+			size:   16,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyers := []Keyer{
+				tt.keyers.Body(),
+				tt.keyers.Query("q"),
+				tt.keyers.Header("Host"),
+				tt.keyers.Path(),
+				tt.keyers.Host(),
+			}
+			for _, keyer := range keyers {
+				key := keyer.Key(req)
+				if len(key) != tt.size {
+					t.Errorf("want %d bytes, got %q", tt.size, key)
+				}
+			}
+		})
+	}
+
+	if got, want := BodyKeyer().Key(req), MD5Digest([]byte("body")); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := PathKeyer().Key(req), Base64Digest([]byte("/a/b")); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := DigestKeyers(Base64Digest).Path().Key(req), PathKeyer().Key(req); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestLimitKeyer(t *testing.T) {
+	long := "/" + strings.Repeat("a", 300)
+	req := httptest.NewRequest("GET", "http://example.com"+long, nil)
+	keyer := LimitKeyer(64, SHA256Digest, JointKeyer(HostKeyer(), PathKeyer()))
+	want := "example.com/" + SHA256Digest([]byte(Base64Digest([]byte(long))))
+	if got := keyer.Key(req); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	req = httptest.NewRequest("GET", "http://example.com/short", nil)
+	want = JointKeyer(HostKeyer(), PathKeyer()).Key(req)
+	if got := keyer.Key(req); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	// Digests longer than the limit are truncated.
+	req = httptest.NewRequest("GET", "http://example.com"+long, nil)
+	keyer = LimitKeyer(16, SHA256Digest, PathKeyer())
+	want = SHA256Digest([]byte(Base64Digest([]byte(long))))[:16]
+	if got := keyer.Key(req); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestKeyersParse(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/a?q=1", nil)
+	keyer, err := DigestKeyers(SHA256Digest).Parse("{host}/{path}/{query:q}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SHA256Digest([]byte("example.com")) + "/" + SHA256Digest([]byte("/a")) + "/" + SHA256Digest([]byte("q=1&"))
+	if got := keyer.Key(req); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestURLKeyer(t *testing.T) {
+	keyer := URLKeyer("utm_*")
+	same := []string{
+		"http://example.com/a/b?x=1&y=2",
+		"HTTP://Example.COM:80/a/b?y=2&x=1",
+		"http://example.com//a/./c/../b/?x=1&utm_source=mail&y=2",
+		"http://example.com/%61/b?y=2&x=%31",
+		"http://example.com/a/b/?x=1&y=2&utm_campaign=x",
+	}
+	want := keyer.Key(httptest.NewRequest("GET", same[0], nil))
+	for _, u := range same[1:] {
+		if got := keyer.Key(httptest.NewRequest("GET", u, nil)); got != want {
+			t.Errorf("%q: want %q, got %q", u, want, got)
+		}
+	}
+
+	different := []string{
+		"https://example.com/a/b?x=1&y=2",
+		"http://example.com:8080/a/b?x=1&y=2",
+		"http://example.com/a/B?x=1&y=2",
+		"http://example.com/a%2Fb?x=1&y=2",
+		"http://example.com/a/b?x=1&y=3",
+		"http://example.com/a/b?x=1&y=2&y=3",
+		"http://example.com/a/b?x=1&y=2&z=%zz",
+		"http://example.com/a/b?x=1&y=2&z=%25zz",
+	}
+	for _, u := range different {
+		if got := keyer.Key(httptest.NewRequest("GET", u, nil)); got == want {
+			t.Errorf("%q: want a different key than %q", u, want)
+		}
+	}
+}
+
+func TestCookieKeyer(t *testing.T) {
+	keyer := CookieKeyer("tier", "bucket")
+	key := func(cookie string) string {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		return keyer.Key(req)
+	}
+	want := key("tier=gold; bucket=b")
+	if got := key("session=1; bucket=b; tier=gold"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := key("tier=silver; bucket=b"); got == want {
+		t.Errorf("want a different key than %q", want)
+	}
+	if got := key("session=1"); got != key("") {
+		t.Errorf("want %q, got %q", key(""), got)
+	}
+}
+
+func TestJSONBodyKeyer(t *testing.T) {
+	keyer := JSONBodyKeyer(1024)
+	key := func(body string) string {
+		return keyer.Key(httptest.NewRequest("POST", "http://example.com/search", strings.NewReader(body)))
+	}
+	want := key(`{"query":"shoes","page":1,"filters":{"size":[42,43],"color":null}}`)
+	for _, body := range []string{
+		`{ "page": 1.0, "query": "shoes", "filters": { "color": null, "size": [42, 43] } }`,
+		"{\n\t\"filters\": {\"size\": [4.2e1, 43]},\n\t\"page\": 1e0,\n\t\"query\": \"shoes\",\"filters\":{\"color\":null,\"size\":[42,43]}\n}",
+	} {
+		if got := key(body); got != want {
+			t.Errorf("%s: want %q, got %q", body, want, got)
+		}
+	}
+	for _, body := range []string{
+		`{"query":"shoes","page":2,"filters":{"size":[42,43],"color":null}}`,
+		`{"query":"shoes","page":1,"filters":{"size":[43,42],"color":null}}`,
+		`{"query":"shoes","page":"1","filters":{"size":[42,43],"color":null}}`,
+	} {
+		if got := key(body); got == want {
+			t.Errorf("%s: want a different key than %q", body, want)
+		}
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	for number, want := range map[string]string{
+		"0":                      "0",
+		"-0.0":                   "0",
+		"1":                      "1",
+		"1.0":                    "1",
+		"10e-1":                  "1",
+		"100":                    "1e2",
+		"0.25":                   "25e-2",
+		"-4.2E1":                 "-42",
+		"1e1000000":              "1e1000000",
+		"10e1000000":             "1e1000001",
+		"1e99999999999999999999": "1e99999999999999999999",
+	} {
+		if got := canonicalNumber(number); got != want {
+			t.Errorf("%s: want %q, got %q", number, want, got)
+		}
+	}
+}
+
+func TestJSONBodyKeyerLargeExponent(t *testing.T) {
+	body := "[" + strings.Repeat("1e1000000,", 100) + "1]"
+	start := time.Now()
+	JSONBodyKeyer(4096).Key(httptest.NewRequest("POST", "http://example.com/", strings.NewReader(body)))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %s", elapsed)
+	}
+}
+
+func TestFormBodyKeyer(t *testing.T) {
+	keyer := FormBodyKeyer(1024)
+	key := func(body string) string {
+		return keyer.Key(httptest.NewRequest("POST", "http://example.com/search", strings.NewReader(body)))
+	}
+	if want, got := key("a=1&b=2+3&c="), key("c=&b=2%203&a=1"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	for _, pair := range [][2]string{
+		{"a=1&b=2", "a=1&b=3"},
+		{"q=%zz", ""},
+		{"q=%zz", "q=%25zz"},
+	} {
+		if want, got := key(pair[0]), key(pair[1]); got == want {
+			t.Errorf("%q and %q: want different keys, got %q", pair[0], pair[1], got)
+		}
+	}
+}
+
+func TestBodyKeyerMaxSize(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(body))
+	keyer := JointKeyer(HostKeyer(), JSONBodyKeyer(10))
+	if _, ok := keyer.(SkipKeyer).KeyOrSkip(req); ok {
+		t.Fatal("want the request to be skipped")
+	}
+	data, _ := io.ReadAll(req.Body)
+	if string(data) != body {
+		t.Fatalf("want %q, got %q", body, data)
+	}
+}
+
+func TestSkipThroughKeyerFunc(t *testing.T) {
+	inner := JSONBodyKeyer(10)
+	origin := newTestOrigin("Cache-Control", "max-age=60")
+	handler := NewHandler(origin,
+		WithFilterer(MethodFilterer(http.MethodPost)),
+		WithKeyer(KeyerFunc(func(req *http.Request) string {
+			return "tenant/" + inner.Key(req)
+		})),
+	)
+	for _, body := range []string{strings.Repeat("a", 100), strings.Repeat("b", 100), `{}`, `{}`} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(body)))
+	}
+	if got := origin.counts["/"]; got != 3 {
+		t.Fatalf("want the large bodies to bypass the cache and 3 origin requests, got %d", got)
+	}
+}
+
+func TestWithDigest(t *testing.T) {
+	storer := MemoryStorer()
+	handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60"), WithStorer(storer), WithDigest(SHA256Digest))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/a", nil))
+	key := SHA256Digest([]byte("example.com")) + "/" + SHA256Digest([]byte("/a"))
+	if _, ok := storer.Get(key); !ok {
+		t.Errorf("expected the entry under %q", key)
+	}
+}
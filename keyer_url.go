@@ -0,0 +1,194 @@
+package httpcache
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// URLKeyer keys on the canonical form of the request URL, see Keyers.URL.
+func URLKeyer(ignore ...string) Keyer {
+	return defaultKeyers.URL(ignore...)
+}
+
+// URL keys on the canonical form of the request URL, so that semantically identical URLs share one entry.
+// The scheme and host are lowercased and the default port is stripped,
+// the path has its dot segments, empty segments and trailing slash removed and its percent-encoding normalized,
+// and the query parameters are sorted by name and value.
+// Query parameters whose names match any of the ignore patterns, e.g. "utm_*", are left out,
+// patterns use the syntax of path.Match.
+func (k Keyers) URL(ignore ...string) Keyer {
+	return k.encoded(func(buf *bytes.Buffer, req *http.Request) []byte {
+		scheme := canonicalScheme(req)
+		buf.WriteString(scheme)
+		buf.WriteString("://")
+		buf.WriteString(canonicalHost(scheme, requestHost(req)))
+		buf.WriteString(canonicalPath(req.URL.EscapedPath()))
+		if query := canonicalQuery(req.URL.RawQuery, ignore); query != "" {
+			buf.WriteByte('?')
+			buf.WriteString(query)
+		}
+		return buf.Bytes()
+	})
+}
+
+func canonicalScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+func canonicalHost(scheme, host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	if port == "" || port == defaultPorts[scheme] {
+		if strings.Contains(h, ":") {
+			return "[" + h + "]"
+		}
+		return h
+	}
+	return host
+}
+
+func canonicalPath(p string) string {
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segment = normalizePercentEncoding(segment)
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(out) != 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+// normalizePercentEncoding decodes percent-encoded unreserved characters
+// and uppercases the hex digits of the remaining escapes, as in RFC 3986 section 6.2.2.
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// canonicalQuery sorts the query parameters by name and value and re-encodes them consistently,
+// leaving out the names matching any of the ignore patterns.
+// Parameters that cannot be decoded are kept as they are, they never match a re-encoded parameter.
+func canonicalQuery(rawQuery string, ignore []string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type param struct {
+		name, value string
+		raw         bool
+	}
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawName, rawValue := pair, ""
+		if i := strings.IndexByte(pair, '='); i != -1 {
+			rawName, rawValue = pair[:i], pair[i+1:]
+		}
+		p := param{name: rawName, value: rawValue, raw: true}
+		name, nameErr := url.QueryUnescape(rawName)
+		value, valueErr := url.QueryUnescape(rawValue)
+		if nameErr == nil && valueErr == nil {
+			p = param{name: name, value: value}
+		}
+		if matchAny(ignore, p.name) {
+			continue
+		}
+		params = append(params, p)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	var b strings.Builder
+	for i, p := range params {
+		if i != 0 {
+			b.WriteByte('&')
+		}
+		if p.raw {
+			b.WriteString(p.name)
+			b.WriteByte('=')
+			b.WriteString(p.value)
+			continue
+		}
+		b.WriteString(url.QueryEscape(p.name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.value))
+	}
+	return b.String()
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
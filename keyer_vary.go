@@ -0,0 +1,186 @@
+package httpcache
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxVaryNames is the number of lists of header names VaryKeyer keeps in memory,
+// the others are read back from the storer.
+const maxVaryNames = 4096
+
+// VaryKeyer keys on the keyer followed by the request headers named in the Vary header of the last stored response,
+// the lists of header names are kept in the storer next to the entries.
+// It implements Varier, so it must be the outermost keyer of a Handler or RoundTripper.
+func VaryKeyer(keyer Keyer, storer Storer) Keyer {
+	return &varyKeyer{
+		keyer:  keyer,
+		storer: storer,
+		header: DigestKeyers(base64URLDigest),
+		names:  map[string][]string{},
+	}
+}
+
+type varyKeyer struct {
+	keyer  Keyer
+	storer Storer
+	header Keyers
+
+	mut   sync.Mutex
+	names map[string][]string
+}
+
+const varySuffix = "/.vary"
+
+func (v *varyKeyer) Key(req *http.Request) string {
+	return SkipKeyerFunc(v.KeyOrSkip).Key(req)
+}
+
+func (v *varyKeyer) KeyOrSkip(req *http.Request) (string, bool) {
+	base, ok := keyOrSkip(v.keyer, req)
+	if !ok {
+		return "", false
+	}
+	return v.key(base, v.load(base), req), true
+}
+
+func (v *varyKeyer) VaryKey(req *http.Request, key string, resp Response) (string, bool) {
+	names, ok := parseVary(resp.Header())
+	if !ok {
+		return "", false
+	}
+	// The base key is not computed again, as the keyer may have consumed the request body.
+	base := ""
+	if i := strings.LastIndexByte(key, '/'); i != -1 {
+		base = key[:i]
+	}
+	if strings.Join(names, "\n") != strings.Join(v.load(base), "\n") {
+		v.store(base, names)
+	}
+	return v.key(base, names, req), true
+}
+
+func (v *varyKeyer) variantPrefix(key string) (string, bool) {
+	i := strings.LastIndexByte(key, '/')
+	if i == -1 {
+		return "", false
+	}
+	return key[:i+1], true
+}
+
+// variants is implemented by keyers that store the variants of a response under a common prefix.
+type variants interface {
+	variantPrefix(key string) (string, bool)
+}
+
+// variantPrefix returns the prefix of the keys of every variant of the entry of the key.
+func variantPrefix(keyer Keyer, key string) (string, bool) {
+	v, ok := keyer.(variants)
+	if !ok {
+		return "", false
+	}
+	return v.variantPrefix(key)
+}
+
+// key appends to the base key a single segment keyed on the named request headers.
+func (v *varyKeyer) key(base string, names []string, req *http.Request) string {
+	key := ""
+	if len(names) != 0 {
+		key = v.header.Header(names...).Key(req)
+	}
+	if key == "" {
+		key = emptyKey
+	}
+	if base == "" {
+		return key
+	}
+	return base + "/" + key
+}
+
+func (v *varyKeyer) load(base string) []string {
+	v.mut.Lock()
+	names, ok := v.names[base]
+	v.mut.Unlock()
+	if ok {
+		return names
+	}
+	r, ok := v.storer.Get(base + varySuffix)
+	if !ok {
+		// Most responses do not vary, so that the record is not looked up again for every request.
+		v.remember(base, nil)
+		return nil
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil
+	}
+	if len(data) != 0 {
+		names = strings.Split(string(data), "\n")
+	}
+	v.remember(base, names)
+	return names
+}
+
+func (v *varyKeyer) store(base string, names []string) {
+	v.remember(base, names)
+	w, ok := v.storer.Put(base + varySuffix)
+	if !ok {
+		return
+	}
+	_, err := io.WriteString(w, strings.Join(names, "\n"))
+	w.Close()
+	if err != nil {
+		v.storer.Del(base + varySuffix)
+	}
+}
+
+// remember keeps the names in memory, an empty list for the keys without a record,
+// dropping an arbitrary list once maxVaryNames are kept.
+func (v *varyKeyer) remember(base string, names []string) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if _, ok := v.names[base]; !ok && len(v.names) >= maxVaryNames {
+		for key := range v.names {
+			delete(v.names, key)
+			break
+		}
+	}
+	v.names[base] = names
+}
+
+// base64URLDigest encodes without '/', so that the headers are keyed in a single segment.
+func base64URLDigest(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseVary returns the sorted canonical header names of the Vary header,
+// or false if the response varies on something other than request headers.
+func parseVary(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+	sort.Strings(names)
+	out := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			out = append(out, name)
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,23 @@
+package httpcache
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// log writes the message about the entry of the request with the key,
+// the key is omitted if empty and the error if nil.
+func (o *option) log(req *http.Request, level slog.Level, msg string, key string, err error) {
+	if o.logger == nil || !o.logger.Enabled(req.Context(), level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 4)
+	if key != "" {
+		attrs = append(attrs, slog.String("key", key))
+	}
+	attrs = append(attrs, slog.String("method", req.Method), slog.String("url", req.URL.String()))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	o.logger.LogAttrs(req.Context(), level, "httpcache: "+msg, attrs...)
+}
@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// refusingStorer refuses every entry.
+type refusingStorer struct {
+	Storer
+}
+
+func (refusingStorer) Put(key string) (io.WriteCloser, bool) {
+	return nil, false
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	storer := MemoryStorer()
+	handler := NewHandler(newTestOrigin(), WithStorer(storer), WithLogger(logger))
+
+	w, _ := storer.Put("example.com/Lw")
+	w.Write([]byte("garbage"))
+	w.Close()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`level=WARN msg="httpcache: corrupt entry" key=example.com/Lw method=GET url=http://example.com/ error=`,
+		`level=DEBUG msg="httpcache: eviction" key=example.com/Lw`,
+		`level=DEBUG msg="httpcache: miss" key=example.com/Lw`,
+		`level=DEBUG msg="httpcache: store" key=example.com/Lw`,
+		`level=DEBUG msg="httpcache: hit" key=example.com/Lw`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("want %d lines, got:\n%s", len(want), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, want[i]) {
+			t.Errorf("want %q in %q", want[i], line)
+		}
+	}
+}
+
+func TestLoggerStoreRefused(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	handler := NewHandler(newTestOrigin(), WithStorer(refusingStorer{MemoryStorer()}), WithLogger(logger))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	want := `level=WARN msg="httpcache: failed to store the entry" key=example.com/Lw method=GET url=http://example.com/ error="storer refused the entry"`
+	if got := strings.TrimSpace(buf.String()); !strings.Contains(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -3,43 +3,96 @@ package httpcache
 import (
 	"bytes"
 	"io"
+	"strings"
 	"sync"
+	"time"
 )
 
 func MemoryStorer() Storer {
 	return &Memory{}
 }
 
+// Memory is a Storer that keeps entries in memory.
+// Stored entries are immutable, a Put replaces the whole entry atomically
+// and readers that already hold an entry keep seeing the old one.
 type Memory struct {
 	m sync.Map
 }
 
+type memoryEntry struct {
+	data    []byte
+	modTime time.Time
+}
+
 func (m *Memory) Get(key string) (io.ReadCloser, bool) {
 	val, ok := m.m.Load(key)
 	if !ok {
 		return nil, false
 	}
-	return io.NopCloser(bytes.NewBuffer(val.(*bytes.Buffer).Bytes())), true
+	return io.NopCloser(bytes.NewReader(val.(*memoryEntry).data)), true
 }
 
 func (m *Memory) Put(key string) (io.WriteCloser, bool) {
 	buffer := getBuffer()
+	var once sync.Once
 	return &writeWithClose{
 		Writer: buffer,
 		close: func() error {
-			val, ok := m.m.LoadOrStore(key, buffer)
-			if ok {
-				putBuffer(val.(*bytes.Buffer))
-			}
+			once.Do(func() {
+				// The pooled buffer is only ever seen by this writer,
+				// so copy it out before the buffer goes back to the pool.
+				data := make([]byte, buffer.Len())
+				copy(data, buffer.Bytes())
+				putBuffer(buffer)
+				m.m.Store(key, &memoryEntry{
+					data:    data,
+					modTime: time.Now(),
+				})
+			})
 			return nil
 		},
 	}, true
 }
 
 func (m *Memory) Del(key string) bool {
-	val, ok := m.m.LoadAndDelete(key)
-	if ok {
-		putBuffer(val.(*bytes.Buffer))
+	_, ok := m.m.LoadAndDelete(key)
+	return ok
+}
+
+// List goes through every entry, a page only keeps the keys that may be in it rather than sorting all of them.
+func (m *Memory) List(prefix, after string, limit int, fn func(key string, size int64) error) error {
+	var page *listPage
+	if limit > 0 {
+		page = newListPage(limit)
+	}
+	var err error
+	m.m.Range(func(key, val interface{}) bool {
+		k := key.(string)
+		if k <= after || !strings.HasPrefix(k, prefix) {
+			return true
+		}
+		size := int64(len(val.(*memoryEntry).data))
+		if page != nil {
+			page.add(k, size)
+			return true
+		}
+		err = fn(k, size)
+		return err == nil
+	})
+	if err != nil || page == nil {
+		return err
 	}
-	return true
+	return page.each(fn)
+}
+
+func (m *Memory) Stat(key string) (EntryInfo, bool) {
+	val, ok := m.m.Load(key)
+	if !ok {
+		return EntryInfo{}, false
+	}
+	entry := val.(*memoryEntry)
+	return EntryInfo{
+		Size:    int64(len(entry.data)),
+		ModTime: entry.modTime,
+	}, true
 }
@@ -0,0 +1,106 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestMemoryOverwrite(t *testing.T) {
+	m := MemoryStorer()
+	for _, want := range []string{"first", "second"} {
+		w, ok := m.Put("key")
+		if !ok {
+			t.Fatal("expected to get the writer")
+		}
+		w.Write([]byte(want))
+		w.Close()
+
+		r, ok := m.Get("key")
+		if !ok {
+			t.Fatal("expected to be available")
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != want {
+			t.Fatalf("want %q, got %q", want, got)
+		}
+	}
+}
+
+func TestMemoryReaderOutlivesOverwrite(t *testing.T) {
+	m := MemoryStorer()
+	put := func(data string) {
+		w, _ := m.Put("key")
+		w.Write([]byte(data))
+		w.Close()
+	}
+	put("old")
+
+	r, ok := m.Get("key")
+	if !ok {
+		t.Fatal("expected to be available")
+	}
+	put("new")
+	m.Del("key")
+	put("newer")
+
+	got, _ := io.ReadAll(r)
+	if string(got) != "old" {
+		t.Fatalf("want %q, got %q", "old", got)
+	}
+}
+
+func TestMemoryRace(t *testing.T) {
+	const size = 4096
+	m := MemoryStorer()
+	values := [][]byte{
+		bytes.Repeat([]byte{'a'}, size),
+		bytes.Repeat([]byte{'b'}, size),
+		bytes.Repeat([]byte{'c'}, size),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i != 8; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j != 200; j++ {
+				w, ok := m.Put("key")
+				if !ok {
+					t.Error("expected to get the writer")
+					return
+				}
+				w.Write(values[(i+j)%len(values)])
+				w.Close()
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 200; j++ {
+				r, ok := m.Get("key")
+				if !ok {
+					continue
+				}
+				data, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(data) != size || bytes.Count(data, data[:1]) != size {
+					t.Errorf("corrupted entry of %d bytes", len(data))
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 200; j++ {
+				m.Del("key")
+			}
+		}()
+	}
+	wg.Wait()
+}
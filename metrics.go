@@ -0,0 +1,203 @@
+package httpcache
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fillBuckets are the upper bounds in seconds of the fill latency histogram.
+var fillBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics is an Observer that also serves what it observed in the Prometheus text exposition format,
+// it is passed to WithObserver and mounted on the metrics path.
+type Metrics struct {
+	name     string
+	storer   Storer
+	counters Counters
+	fill     histogram
+
+	refresh time.Duration
+	mut     sync.Mutex
+	walked  time.Time
+	usage   storerUsage
+}
+
+type MetricsOption func(m *Metrics)
+
+// WithStorerRefresh sets how often the storer is walked to report its size and number of entries,
+// the default is a minute. An interval of zero or less walks it on every scrape.
+func WithStorerRefresh(interval time.Duration) MetricsOption {
+	return func(m *Metrics) {
+		m.refresh = interval
+	}
+}
+
+// NewMetrics returns Metrics labeled with the cache name.
+// The size and the number of entries of the storer are only reported if it is a Lister,
+// walking it at most once per refresh interval.
+func NewMetrics(name string, storer Storer, options ...MetricsOption) *Metrics {
+	m := &Metrics{
+		name:    name,
+		storer:  storer,
+		fill:    newHistogram(fillBuckets),
+		refresh: time.Minute,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+func (m *Metrics) Observe(event Event) {
+	m.counters.Observe(event)
+	if event.Kind == EventStore {
+		m.fill.observe(event.Duration)
+	}
+}
+
+// outcomes are the events that end a lookup.
+var outcomes = []EventKind{EventHit, EventStaleHit, EventMiss, EventRefetch, EventBypass}
+
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w := bufio.NewWriter(rw)
+	defer w.Flush()
+
+	label := `cache="` + escapeLabelValue(m.name) + `"`
+	s := m.counters.Snapshot()
+
+	writeMetricHeader(w, "httpcache_requests_total", "counter", "Requests by outcome of the lookup.")
+	for _, kind := range outcomes {
+		writeMetric(w, "httpcache_requests_total", label+`,outcome="`+kind.String()+`"`, float64(m.counters.Count(kind)))
+	}
+
+	writeMetricHeader(w, "httpcache_events_total", "counter", "Events of the entries.")
+	for _, kind := range []EventKind{EventDiscard, EventStore, EventStoreFailure, EventEviction, EventCoalesced} {
+		writeMetric(w, "httpcache_events_total", label+`,event="`+kind.String()+`"`, float64(m.counters.Count(kind)))
+	}
+
+	ratio := 0.0
+	if lookups := s.Hits + s.StaleHits + s.Misses + s.Refetches; lookups != 0 {
+		ratio = float64(s.Hits+s.StaleHits) / float64(lookups)
+	}
+	writeMetricHeader(w, "httpcache_hit_ratio", "gauge", "Ratio of the lookups served from the cache.")
+	writeMetric(w, "httpcache_hit_ratio", label, ratio)
+
+	writeMetricHeader(w, "httpcache_served_bytes_total", "counter", "Bytes of the bodies served from the cache.")
+	writeMetric(w, "httpcache_served_bytes_total", label, float64(s.BytesServed))
+
+	writeMetricHeader(w, "httpcache_stored_bytes_total", "counter", "Bytes of the bodies stored.")
+	writeMetric(w, "httpcache_stored_bytes_total", label, float64(s.BytesStored))
+
+	if usage, ok := m.storerUsage(); ok {
+		writeMetricHeader(w, "httpcache_storer_entries", "gauge", "Entries in the storer.")
+		writeMetric(w, "httpcache_storer_entries", label, float64(usage.entries))
+		writeMetricHeader(w, "httpcache_storer_bytes", "gauge", "Bytes in the storer.")
+		writeMetric(w, "httpcache_storer_bytes", label, float64(usage.bytes))
+	}
+
+	writeMetricHeader(w, "httpcache_fill_duration_seconds", "histogram", "Time spent fetching and storing the responses.")
+	m.fill.write(w, "httpcache_fill_duration_seconds", label)
+}
+
+type storerUsage struct {
+	entries int64
+	bytes   int64
+}
+
+// storerUsage returns the size and the number of entries of the storer, walking it if the last walk is too old.
+func (m *Metrics) storerUsage() (storerUsage, bool) {
+	lister, ok := m.storer.(Lister)
+	if !ok {
+		return storerUsage{}, false
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if !m.walked.IsZero() && time.Since(m.walked) < m.refresh {
+		return m.usage, true
+	}
+	var usage storerUsage
+	err := lister.List("", "", 0, func(key string, size int64) error {
+		if isRecordKey(key) {
+			return nil
+		}
+		usage.entries++
+		usage.bytes += size
+		return nil
+	})
+	if err != nil {
+		return storerUsage{}, false
+	}
+	m.usage = usage
+	m.walked = time.Now()
+	return usage, true
+}
+
+// histogram counts durations in cumulative buckets.
+type histogram struct {
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			atomic.AddInt64(&h.counts[i], 1)
+		}
+	}
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddInt64(&h.count, 1)
+}
+
+func (h *histogram) write(w *bufio.Writer, name, label string) {
+	for i, bound := range h.bounds {
+		writeMetric(w, name+"_bucket", label+`,le="`+formatMetricValue(bound)+`"`, float64(atomic.LoadInt64(&h.counts[i])))
+	}
+	count := float64(atomic.LoadInt64(&h.count))
+	writeMetric(w, name+"_bucket", label+`,le="+Inf"`, count)
+	writeMetric(w, name+"_sum", label, time.Duration(atomic.LoadInt64(&h.sum)).Seconds())
+	writeMetric(w, name+"_count", label, count)
+}
+
+func writeMetricHeader(w *bufio.Writer, name, typ, help string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func writeMetric(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name + "{" + labels + "} " + formatMetricValue(value) + "\n")
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
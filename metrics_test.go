@@ -0,0 +1,84 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	storer := MemoryStorer()
+	metrics := NewMetrics(`api "v1"`, storer)
+	handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60"), WithStorer(storer), WithObserver(metrics))
+	for _, path := range []string{"/200", "/200", "/200", "/500"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	metrics.Observe(Event{Kind: EventStore, Duration: 2 * time.Second})
+
+	rw := httptest.NewRecorder()
+	metrics.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rw.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", got)
+	}
+	body := rw.Body.String()
+	for _, want := range []string{
+		"# TYPE httpcache_requests_total counter\n",
+		`httpcache_requests_total{cache="api \"v1\"",outcome="hit"} 2` + "\n",
+		`httpcache_requests_total{cache="api \"v1\"",outcome="miss"} 2` + "\n",
+		`httpcache_events_total{cache="api \"v1\"",event="discard"} 1` + "\n",
+		`httpcache_hit_ratio{cache="api \"v1\""} 0.5` + "\n",
+		`httpcache_stored_bytes_total{cache="api \"v1\""} 1` + "\n",
+		`httpcache_storer_entries{cache="api \"v1\""} 1` + "\n",
+		"# TYPE httpcache_fill_duration_seconds histogram\n",
+		`httpcache_fill_duration_seconds_bucket{cache="api \"v1\"",le="1"} 1` + "\n",
+		`httpcache_fill_duration_seconds_bucket{cache="api \"v1\"",le="2.5"} 2` + "\n",
+		`httpcache_fill_duration_seconds_bucket{cache="api \"v1\"",le="+Inf"} 2` + "\n",
+		`httpcache_fill_duration_seconds_count{cache="api \"v1\""} 2` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsStorerUsage(t *testing.T) {
+	storer := MemoryStorer()
+	put := func(key, data string) {
+		w, _ := storer.Put(key)
+		w.Write([]byte(data))
+		w.Close()
+	}
+	put("example.com/a", "aaa")
+	put("example.com/a/.vary", "accept")
+	put("ns/generation", "1")
+
+	scrape := func(metrics *Metrics) string {
+		rw := httptest.NewRecorder()
+		metrics.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rw.Body.String()
+	}
+
+	cached := NewMetrics("c", storer)
+	every := NewMetrics("c", storer, WithStorerRefresh(0))
+	for _, metrics := range []*Metrics{cached, every} {
+		body := scrape(metrics)
+		for _, want := range []string{
+			`httpcache_storer_entries{cache="c"} 1` + "\n",
+			`httpcache_storer_bytes{cache="c"} 3` + "\n",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("missing %q in:\n%s", want, body)
+			}
+		}
+	}
+
+	put("example.com/b", "bb")
+	if want := `httpcache_storer_entries{cache="c"} 1` + "\n"; !strings.Contains(scrape(cached), want) {
+		t.Errorf("expected the walk to be cached until the refresh")
+	}
+	if want := `httpcache_storer_entries{cache="c"} 2` + "\n"; !strings.Contains(scrape(every), want) {
+		t.Errorf("expected the storer to be walked on every scrape")
+	}
+}
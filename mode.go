@@ -0,0 +1,45 @@
+package httpcache
+
+import (
+	"net/http"
+)
+
+// Mode tells whether the cache is shared by many users or private to one.
+type Mode int
+
+const (
+	// SharedMode is for caches in front of many users, such as a Handler in a reverse proxy,
+	// it is the default of Handler.
+	// Responses marked private are not stored, s-maxage takes precedence over max-age,
+	// and responses to requests with Authorization are only stored if public, must-revalidate or s-maxage allow it.
+	SharedMode Mode = iota + 1
+	// PrivateMode is for caches of a single user, such as a RoundTripper in a client,
+	// it is the default of RoundTripper. Responses marked private are stored.
+	PrivateMode
+)
+
+// storable reports whether the response to the request may be stored,
+// as in RFC 9111 section 3.
+func (o *option) storable(req *http.Request, resp Response) bool {
+	if parseCacheControl(req.Header.Values("Cache-Control")).has("no-store") {
+		return false
+	}
+	directives := parseCacheControl(resp.Header().Values("Cache-Control"))
+	if directives.has("no-store") {
+		return false
+	}
+	// Entries are never revalidated, so responses that must be revalidated before every use are not stored.
+	if directives.has("no-cache") {
+		return false
+	}
+	if o.mode != SharedMode {
+		return true
+	}
+	if directives.has("private") {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" {
+		return directives.has("public") || directives.has("must-revalidate") || directives.has("s-maxage")
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          Mode
+		authorization bool
+		cacheControl  string
+		stored        bool
+	}{
+		{"shared public", SharedMode, false, "max-age=60", true},
+		{"shared private", SharedMode, false, "private, max-age=60", false},
+		{"shared no-store", SharedMode, false, "no-store", false},
+		{"shared authorization", SharedMode, true, "max-age=60", false},
+		{"shared authorization public", SharedMode, true, "public, max-age=60", true},
+		{"shared authorization must-revalidate", SharedMode, true, "must-revalidate, max-age=60", true},
+		{"shared authorization s-maxage", SharedMode, true, "s-maxage=60", true},
+		{"private private", PrivateMode, false, "private, max-age=60", true},
+		{"private authorization", PrivateMode, true, "max-age=60", true},
+		{"private no-store", PrivateMode, false, "no-store", false},
+	}
+	for _, tt := range tests {
+		caches := testCaches(func() *testOrigin { return newTestOrigin("Cache-Control", tt.cacheControl) }, WithMode(tt.mode))
+		for name, do := range caches {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				get := func() string {
+					req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+					if tt.authorization {
+						req.Header.Set("Authorization", "Bearer token")
+					}
+					return readBodyString(t, do(req))
+				}
+				get()
+				want := "2"
+				if tt.stored {
+					want = "1"
+				}
+				if got := get(); got != want {
+					t.Fatalf("want %q, got %q", want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestModeSMaxAge(t *testing.T) {
+	for mode, fresh := range map[Mode]time.Duration{SharedMode: 10 * time.Second, PrivateMode: time.Minute} {
+		clock := newTestClock()
+		caches := testCaches(func() *testOrigin { return newTestOrigin("Cache-Control", "max-age=60, s-maxage=10") }, WithMode(mode), withClock(clock))
+		for name, do := range caches {
+			get := func() string {
+				return readBodyString(t, do(httptest.NewRequest(http.MethodGet, "http://example.com/", nil)))
+			}
+			get()
+			clock.Add(fresh - time.Second)
+			if got := get(); got != "1" {
+				t.Fatalf("%s mode %d: want %q, got %q", name, mode, "1", got)
+			}
+			clock.Add(time.Second)
+			if got := get(); got != "2" {
+				t.Fatalf("%s mode %d: want %q, got %q", name, mode, "2", got)
+			}
+		}
+	}
+}
+
+func TestStripHeaders(t *testing.T) {
+	header := []string{
+		"Set-Cookie", "session=secret",
+		"Connection", "X-Hop",
+		"X-Hop", "1",
+		"Keep-Alive", "timeout=5",
+		"Proxy-Authenticate", "Basic",
+		"X-Internal", "1",
+		"X-Kept", "1",
+	}
+	tests := []struct {
+		name    string
+		options []Option
+		removed []string
+		kept    []string
+	}{
+		{
+			name:    "shared",
+			options: []Option{WithMode(SharedMode)},
+			removed: []string{"Set-Cookie", "Connection", "X-Hop", "Keep-Alive", "Proxy-Authenticate"},
+			kept:    []string{"X-Internal", "X-Kept"},
+		},
+		{
+			name:    "private",
+			options: []Option{WithMode(PrivateMode)},
+			removed: []string{"Connection", "X-Hop", "Keep-Alive", "Proxy-Authenticate"},
+			kept:    []string{"Set-Cookie", "X-Internal", "X-Kept"},
+		},
+		{
+			name:    "custom",
+			options: []Option{WithMode(SharedMode), WithStripHeaders("X-Internal")},
+			removed: []string{"Set-Cookie", "X-Internal", "Connection", "X-Hop"},
+			kept:    []string{"X-Kept"},
+		},
+		{
+			name:    "keep cookie",
+			options: []Option{WithMode(SharedMode), WithStripHeaders("X-Internal"), WithKeepSetCookie()},
+			removed: []string{"X-Internal", "Connection", "X-Hop"},
+			kept:    []string{"Set-Cookie", "X-Kept"},
+		},
+		{
+			name:    "private custom",
+			options: []Option{WithMode(PrivateMode), WithStripHeaders("Set-Cookie")},
+			removed: []string{"Set-Cookie", "Connection"},
+			kept:    []string{"X-Internal", "X-Kept"},
+		},
+	}
+	for _, tt := range tests {
+		caches := testCaches(func() *testOrigin { return newTestOrigin(header...) }, tt.options...)
+		for name, do := range caches {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				get := func() *http.Response {
+					resp := do(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+					readBodyString(t, resp)
+					return resp
+				}
+				if got := get().Header.Get("Set-Cookie"); got != "session=secret" {
+					t.Fatalf("want the cookie on the miss, got %q", got)
+				}
+				hit := get()
+				if got := hit.Header.Get("Cache-Status"); got == "" {
+					t.Fatal("want a hit")
+				}
+				for _, name := range tt.removed {
+					if got := hit.Header.Get(name); got != "" {
+						t.Errorf("want %s removed, got %q", name, got)
+					}
+				}
+				for _, name := range tt.kept {
+					if got := hit.Header.Get(name); got == "" {
+						t.Errorf("want %s kept", name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSessionCookieNeverReplayed(t *testing.T) {
+	origin := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil {
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "alice-secret"})
+		} else {
+			rw.Header().Set("X-Session", cookie.Value)
+		}
+		rw.Write([]byte("page"))
+	})
+	server := httptest.NewServer(NewHandler(origin))
+	defer server.Close()
+
+	for i, user := range []string{"alice", "bob", "carol"} {
+		resp, err := http.Get(server.URL + "/page")
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBodyString(t, resp)
+		cookies := resp.Cookies()
+		if i == 0 {
+			if len(cookies) != 1 {
+				t.Fatalf("%s: want the session cookie, got %v", user, cookies)
+			}
+			continue
+		}
+		if len(cookies) != 0 {
+			t.Fatalf("%s: session cookie replayed: %v", user, cookies)
+		}
+	}
+}
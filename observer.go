@@ -0,0 +1,155 @@
+package httpcache
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind is what happened to a request or to an entry.
+type EventKind int
+
+const (
+	// EventHit is a request served with a fresh entry, Bytes is the size of the body.
+	EventHit EventKind = iota + 1
+	// EventStaleHit is a request served with a stale entry the request accepts, Bytes is the size of the body.
+	EventStaleHit
+	// EventMiss is a request sent to the origin as there is no entry for it.
+	EventMiss
+	// EventRefetch is a request sent to the origin although there is an entry for it,
+	// as the entry is stale or not acceptable to the request, or the request asks for no-cache.
+	// Entries are never revalidated, the response of the origin replaces the entry.
+	EventRefetch
+	// EventBypass is a request rejected by the Filterer or skipped by the Keyer.
+	EventBypass
+	// EventDiscard is a response that is not stored.
+	EventDiscard
+	// EventStore is a response stored, Bytes is the size of the body
+	// and Duration the time spent fetching and storing it.
+	EventStore
+	// EventStoreFailure is a response that the Storer failed to store.
+	EventStoreFailure
+	// EventEviction is an entry removed from the Storer: by Handler or RoundTripper, such as an entry
+	// that cannot be read back or one invalidated by PURGE or BAN, by Admin and GenerationKeyer.Sweep
+	// given the Observer with WithAdminObserver and WithSweepObserver, and by storers implementing Evicter.
+	EventEviction
+	// EventCoalesced is a request that waited for another request to fill the same key,
+	// Duration is the time spent waiting.
+	EventCoalesced
+
+	numEventKinds = iota + 1
+)
+
+var eventKindNames = [numEventKinds]string{
+	EventHit:          "hit",
+	EventStaleHit:     "stale_hit",
+	EventMiss:         "miss",
+	EventRefetch:      "refetch",
+	EventBypass:       "bypass",
+	EventDiscard:      "discard",
+	EventStore:        "store",
+	EventStoreFailure: "store_failure",
+	EventEviction:     "eviction",
+	EventCoalesced:    "coalesced",
+}
+
+func (k EventKind) String() string {
+	if k <= 0 || k >= numEventKinds {
+		return "unknown"
+	}
+	return eventKindNames[k]
+}
+
+// Event is reported to the Observer.
+type Event struct {
+	Kind     EventKind
+	Key      string
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Observer is notified of the events of Handler and RoundTripper.
+// It is called synchronously and must be safe for concurrent use.
+type Observer interface {
+	Observe(event Event)
+}
+
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// observe notifies the Observer of the event of the request and logs it at the debug level.
+func (o *option) observe(req *http.Request, event Event) {
+	if o.observer != nil {
+		o.observer.Observe(event)
+	}
+	o.log(req, slog.LevelDebug, event.Kind.String(), event.Key, nil)
+}
+
+// Counters is an Observer counting the events atomically.
+type Counters struct {
+	counts      [numEventKinds]int64
+	bytesServed int64
+	bytesStored int64
+}
+
+func NewCounters() *Counters {
+	return &Counters{}
+}
+
+func (c *Counters) Observe(event Event) {
+	if event.Kind <= 0 || event.Kind >= numEventKinds {
+		return
+	}
+	atomic.AddInt64(&c.counts[event.Kind], 1)
+	switch event.Kind {
+	case EventHit, EventStaleHit:
+		atomic.AddInt64(&c.bytesServed, event.Bytes)
+	case EventStore:
+		atomic.AddInt64(&c.bytesStored, event.Bytes)
+	}
+}
+
+// CountersSnapshot is a copy of the Counters at one point in time.
+type CountersSnapshot struct {
+	Hits          int64
+	StaleHits     int64
+	Misses        int64
+	Refetches     int64
+	Bypasses      int64
+	Discards      int64
+	Stores        int64
+	StoreFailures int64
+	Evictions     int64
+	Coalesced     int64
+	BytesServed   int64
+	BytesStored   int64
+}
+
+// Count returns the number of events of the kind.
+func (c *Counters) Count(kind EventKind) int64 {
+	if kind <= 0 || kind >= numEventKinds {
+		return 0
+	}
+	return atomic.LoadInt64(&c.counts[kind])
+}
+
+func (c *Counters) Snapshot() CountersSnapshot {
+	return CountersSnapshot{
+		Hits:          c.Count(EventHit),
+		StaleHits:     c.Count(EventStaleHit),
+		Misses:        c.Count(EventMiss),
+		Refetches:     c.Count(EventRefetch),
+		Bypasses:      c.Count(EventBypass),
+		Discards:      c.Count(EventDiscard),
+		Stores:        c.Count(EventStore),
+		StoreFailures: c.Count(EventStoreFailure),
+		Evictions:     c.Count(EventEviction),
+		Coalesced:     c.Count(EventCoalesced),
+		BytesServed:   atomic.LoadInt64(&c.bytesServed),
+		BytesStored:   atomic.LoadInt64(&c.bytesStored),
+	}
+}
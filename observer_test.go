@@ -0,0 +1,129 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	clock := newTestClock()
+	for _, name := range []string{"Handler", "RoundTripper"} {
+		t.Run(name, func(t *testing.T) {
+			counters := NewCounters()
+			caches := testCaches(func() *testOrigin { return newTestOrigin("Cache-Control", "max-age=60") },
+				WithObserver(counters),
+				WithFilterer(FiltererFunc(func(r *http.Request) bool { return r.URL.Path != "/bypass" })),
+				withClock(clock),
+			)
+			do := caches[name]
+			for _, path := range []string{"/200", "/200", "/bypass", "/500"} {
+				readBodyString(t, do(httptest.NewRequest(http.MethodGet, path, nil)))
+			}
+			clock.Add(time.Minute)
+			readBodyString(t, do(httptest.NewRequest(http.MethodGet, "/200", nil)))
+
+			want := CountersSnapshot{
+				Hits:        1,
+				Misses:      2,
+				Refetches:   1,
+				Bypasses:    1,
+				Discards:    1,
+				Stores:      2,
+				BytesServed: 1,
+				BytesStored: 2,
+			}
+			if got := counters.Snapshot(); got != want {
+				t.Errorf("want %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestObserverCoalesced(t *testing.T) {
+	release := make(chan struct{})
+	origin := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+		rw.Write([]byte("OK"))
+	})
+	counters := NewCounters()
+	handler := NewHandler(origin, WithObserver(counters))
+
+	const n = 4
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i != n; i++ {
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	for counters.Count(EventMiss) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	got := counters.Snapshot()
+	if got.Misses != 1 || got.Stores != 1 || got.Hits != n-1 || got.Coalesced == 0 {
+		t.Errorf("unexpected %+v", got)
+	}
+}
+
+func TestEventKindString(t *testing.T) {
+	if got := EventStaleHit.String(); got != "stale_hit" {
+		t.Errorf("want %q, got %q", "stale_hit", got)
+	}
+	if got := EventKind(0).String(); got != "unknown" {
+		t.Errorf("want %q, got %q", "unknown", got)
+	}
+}
+
+type testEvicter struct {
+	Storer
+	observer Observer
+}
+
+func (s *testEvicter) ObserveEvictions(observer Observer) {
+	s.observer = observer
+}
+
+func TestEvictionObservers(t *testing.T) {
+	storer := MemoryStorer()
+	put := func(keys ...string) {
+		for _, key := range keys {
+			w, _ := storer.Put(key)
+			w.Close()
+		}
+	}
+
+	counters := NewCounters()
+	admin := NewAdmin(storer, func(r *http.Request) bool { return true }, WithAdminObserver(counters))
+	put("a/1", "a/2", "b")
+	admin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/entry?key=b", nil))
+	admin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/purge?prefix=a/", nil))
+	if got := counters.Count(EventEviction); got != 3 {
+		t.Errorf("Admin: want 3 evictions, got %d", got)
+	}
+
+	counters = NewCounters()
+	keyer := NewGenerationKeyer("site", PathKeyer(), storer, WithSweepObserver(counters))
+	put("site/g0/a", "site/g0/b")
+	keyer.Bump()
+	if _, err := keyer.Sweep(); err != nil {
+		t.Fatal(err)
+	}
+	if got := counters.Count(EventEviction); got != 2 {
+		t.Errorf("Sweep: want 2 evictions, got %d", got)
+	}
+
+	counters = NewCounters()
+	evicter := &testEvicter{Storer: MemoryStorer()}
+	NewHandler(newTestOrigin(), WithStorer(evicter), WithObserver(counters))
+	if evicter.observer != counters {
+		t.Error("want the Observer passed to the Evicter")
+	}
+}
@@ -1,8 +1,10 @@
 package httpcache
 
 import (
+	"log/slog"
 	"net/http"
 	"sync"
+	"time"
 )
 
 type Option func(c *option)
@@ -11,17 +13,37 @@ type option struct {
 	filterer  Filterer
 	discarder Discarder
 	keyer     Keyer
+	digest    Digest
 	storer    Storer
+	mode      Mode
+	statusTTL StatusTTL
 
+	stripHeaders  []string
+	keepSetCookie bool
+
+	observer Observer
+	tracer   Tracer
+	logger   *slog.Logger
+
+	purgeAuth func(r *http.Request) bool
+
+	heuristicFraction float64
+	heuristicMax      time.Duration
+
+	now  func() time.Time
 	muts sync.Map
 }
 
-func (o *option) init(options []Option) {
+func (o *option) init(mode Mode, options []Option) {
+	o.mode = mode
+	o.heuristicFraction = 0.1
+	o.heuristicMax = 24 * time.Hour
 	for _, option := range options {
 		option(o)
 	}
 	if o.keyer == nil {
-		o.keyer = JointKeyer(HostKeyer(), PathKeyer())
+		keyers := DigestKeyers(o.digest)
+		o.keyer = JointKeyer(keyers.Host(), keyers.Path())
 	}
 	if o.storer == nil {
 		o.storer = MemoryStorer()
@@ -32,6 +54,12 @@ func (o *option) init(options []Option) {
 	if o.discarder == nil {
 		o.discarder = NormalDiscarder()
 	}
+	if o.now == nil {
+		o.now = time.Now
+	}
+	if evicter, ok := o.storer.(Evicter); ok && o.observer != nil {
+		evicter.ObserveEvictions(o.observer)
+	}
 }
 
 func WithStorer(storer Storer) func(c *option) {
@@ -46,6 +74,14 @@ func WithKeyer(keyer Keyer) func(c *option) {
 	}
 }
 
+// WithDigest sets the Digest of the default keyer, which keys on the host and the path,
+// use the keyers of DigestKeyers or Keyers.Parse for other keys.
+func WithDigest(digest Digest) func(c *option) {
+	return func(c *option) {
+		c.digest = digest
+	}
+}
+
 func WithFilterer(filterer Filterer) func(c *option) {
 	return func(c *option) {
 		c.filterer = filterer
@@ -57,3 +93,77 @@ func WithDiscarder(discarder Discarder) func(c *option) {
 		c.discarder = discarder
 	}
 }
+
+// WithStatusTTL sets how long responses stay fresh depending on their status code,
+// e.g. StatusTTL{200: time.Hour, 404: 30 * time.Second, 5: 0}.
+func WithStatusTTL(ttl StatusTTL) func(c *option) {
+	return func(c *option) {
+		c.statusTTL = ttl
+	}
+}
+
+// WithHeuristicFreshness sets the fraction of the time since Last-Modified that responses
+// without explicit freshness stay fresh, capped by max. A fraction of zero disables the heuristic.
+// The default is 10% up to a day.
+func WithHeuristicFreshness(fraction float64, max time.Duration) func(c *option) {
+	return func(c *option) {
+		c.heuristicFraction = fraction
+		c.heuristicMax = max
+	}
+}
+
+// WithMode sets whether the cache is shared or private,
+// by default Handler is a shared cache and RoundTripper a private one.
+func WithMode(mode Mode) func(c *option) {
+	return func(c *option) {
+		c.mode = mode
+	}
+}
+
+// WithStripHeaders adds response headers removed before responses are stored.
+// Hop-by-hop headers and the headers named in Connection are always removed,
+// and Set-Cookie is removed in SharedMode unless WithKeepSetCookie is set.
+func WithStripHeaders(names ...string) func(c *option) {
+	return func(c *option) {
+		c.stripHeaders = append(c.stripHeaders, names...)
+	}
+}
+
+// WithKeepSetCookie stores Set-Cookie in SharedMode, which replays the cookie of one user to every other user,
+// so it is only meant for origins whose cookies are not tied to a user.
+func WithKeepSetCookie() func(c *option) {
+	return func(c *option) {
+		c.keepSetCookie = true
+	}
+}
+
+// WithObserver sets the Observer notified of hits, misses, stores and the other events of the cache.
+func WithObserver(observer Observer) func(c *option) {
+	return func(c *option) {
+		c.observer = observer
+	}
+}
+
+// WithTracer sets the Tracer recording the spans of computing the key, looking up, waiting for,
+// fetching and storing the entries.
+func WithTracer(tracer Tracer) func(c *option) {
+	return func(c *option) {
+		c.tracer = tracer
+	}
+}
+
+// WithLogger sets the logger of the errors of the cache, such as entries that cannot be read back
+// or stored, and at the debug level of every decision of the cache.
+func WithLogger(logger *slog.Logger) func(c *option) {
+	return func(c *option) {
+		c.logger = logger
+	}
+}
+
+// WithPurge makes Handler answer the PURGE and BAN methods of the requests that auth allows,
+// other PURGE and BAN requests are refused with 403.
+func WithPurge(auth func(r *http.Request) bool) func(c *option) {
+	return func(c *option) {
+		c.purgeAuth = auth
+	}
+}
@@ -0,0 +1,106 @@
+package httpcache
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// Methods to invalidate the entries of a Handler, enabled by WithPurge.
+const (
+	// MethodPurge deletes the entry of the request, keyed as if it were a GET,
+	// with a Varier keyer such as VaryKeyer it deletes every variant of the entry.
+	MethodPurge = "PURGE"
+	// MethodBan deletes every entry whose key starts with the X-Ban-Prefix header
+	// or matches the X-Ban-Regexp header, it needs a storer that is a Lister.
+	MethodBan = "BAN"
+)
+
+// serveInvalidation answers PURGE and BAN requests with 200 if entries were deleted, 404 if not,
+// 403 if the request is not authorized, 400 if a BAN is invalid and 501 if a BAN is not supported.
+func (h *Handler) serveInvalidation(rw http.ResponseWriter, r *http.Request) {
+	if !h.purgeAuth(r) {
+		invalidationResponse(rw, http.StatusForbidden, 0)
+		return
+	}
+	if r.Method == MethodPurge {
+		h.purge(rw, r)
+		return
+	}
+	h.ban(rw, r)
+}
+
+func (h *Handler) purge(rw http.ResponseWriter, r *http.Request) {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	key, ok := keyRequest(h.keyer, req)
+	if !ok {
+		invalidationResponse(rw, http.StatusNotFound, 0)
+		return
+	}
+	var deleted []string
+	lister, isLister := h.storer.(Lister)
+	// The key is the one of the variant of the PURGE request, which is usually not the one cached.
+	if prefix, ok := variantPrefix(h.keyer, key); ok && isLister {
+		var err error
+		deleted, err = purgePrefix(lister, h.storer, prefix, nil)
+		if err != nil {
+			h.log(r, slog.LevelError, "failed to purge the variants", key, err)
+			invalidationResponse(rw, http.StatusInternalServerError, 0)
+			return
+		}
+	} else if h.storer.Del(key) {
+		deleted = []string{key}
+	}
+	h.invalidated(rw, r, deleted)
+}
+
+func (h *Handler) ban(rw http.ResponseWriter, r *http.Request) {
+	lister, ok := h.storer.(Lister)
+	if !ok {
+		invalidationResponse(rw, http.StatusNotImplemented, 0)
+		return
+	}
+	prefix := r.Header.Get("X-Ban-Prefix")
+	var re *regexp.Regexp
+	if expr := r.Header.Get("X-Ban-Regexp"); expr != "" {
+		var err error
+		re, err = regexp.Compile(expr)
+		if err != nil {
+			invalidationResponse(rw, http.StatusBadRequest, 0)
+			return
+		}
+	} else if prefix == "" {
+		invalidationResponse(rw, http.StatusBadRequest, 0)
+		return
+	}
+
+	deleted, err := purgePrefix(lister, h.storer, prefix, re)
+	if err != nil {
+		h.log(r, slog.LevelError, "failed to ban the entries", "", err)
+		invalidationResponse(rw, http.StatusInternalServerError, 0)
+		return
+	}
+	h.invalidated(rw, r, deleted)
+}
+
+// invalidated reports the deleted entries and answers with their number.
+func (h *Handler) invalidated(rw http.ResponseWriter, r *http.Request, deleted []string) {
+	for _, key := range deleted {
+		h.observe(r, Event{Kind: EventEviction, Key: key})
+	}
+	if len(deleted) == 0 {
+		invalidationResponse(rw, http.StatusNotFound, 0)
+		return
+	}
+	invalidationResponse(rw, http.StatusOK, len(deleted))
+}
+
+// invalidationResponse writes the status and the number of entries deleted.
+func invalidationResponse(rw http.ResponseWriter, code int, n int) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("X-Httpcache-Deleted", strconv.Itoa(n))
+	rw.WriteHeader(code)
+	rw.Write([]byte(http.StatusText(code) + "\n"))
+}
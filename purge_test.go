@@ -0,0 +1,120 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	counters := NewCounters()
+	handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60"),
+		WithObserver(counters),
+		WithPurge(func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer secret"
+		}),
+	)
+	do := func(method, target string, header ...string) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw.Result()
+	}
+	fill := func() {
+		for _, path := range []string{"/a/1", "/a/2", "/b"} {
+			do(http.MethodGet, "http://example.com"+path)
+		}
+	}
+	fill()
+	if got := readBodyString(t, do(http.MethodGet, "http://example.com/a/1")); got != "1" {
+		t.Fatalf("want a hit, got %q", got)
+	}
+
+	steps := []struct {
+		method string
+		target string
+		header []string
+		want   int
+	}{
+		{MethodPurge, "http://example.com/a/1", nil, http.StatusForbidden},
+		{MethodPurge, "http://example.com/a/1", []string{"Authorization", "Bearer secret"}, http.StatusOK},
+		{MethodPurge, "http://example.com/a/1", []string{"Authorization", "Bearer secret"}, http.StatusNotFound},
+		{MethodBan, "http://example.com/", []string{"Authorization", "Bearer secret"}, http.StatusBadRequest},
+		{MethodBan, "http://example.com/", []string{"Authorization", "Bearer secret", "X-Ban-Regexp", "("}, http.StatusBadRequest},
+		{MethodBan, "http://example.com/", []string{"Authorization", "Bearer secret", "X-Ban-Prefix", "example.org/"}, http.StatusNotFound},
+		{MethodBan, "http://example.com/", []string{"Authorization", "Bearer secret", "X-Ban-Prefix", "example.com/"}, http.StatusOK},
+	}
+	for _, step := range steps {
+		resp := do(step.method, step.target, step.header...)
+		if resp.StatusCode != step.want {
+			t.Errorf("%s %s %q: want %d, got %d", step.method, step.target, step.header, step.want, resp.StatusCode)
+		}
+	}
+	if got := counters.Count(EventEviction); got != 3 {
+		t.Errorf("want 3 evictions, got %d", got)
+	}
+	if got := readBodyString(t, do(http.MethodGet, "http://example.com/a/1")); got != "2" {
+		t.Errorf("want a miss after the purge, got %q", got)
+	}
+
+	fill()
+	resp := do(MethodBan, "http://example.com/", "Authorization", "Bearer secret", "X-Ban-Regexp", "^example\\.com/")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Httpcache-Deleted") != "3" {
+		t.Errorf("unexpected %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestPurgeDisabled(t *testing.T) {
+	handler := NewHandler(newTestOrigin())
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(MethodPurge, "/", nil))
+	if rw.Body.String() != "1" {
+		t.Errorf("want the request to reach the origin, got %q", rw.Body.String())
+	}
+}
+
+func TestPurgeVariants(t *testing.T) {
+	for _, name := range []string{"VaryKeyer", "GenerationKeyer"} {
+		t.Run(name, func(t *testing.T) {
+			storer := MemoryStorer()
+			keyer := VaryKeyer(JointKeyer(HostKeyer(), PathKeyer()), storer)
+			if name == "GenerationKeyer" {
+				keyer = NewGenerationKeyer("site", keyer, storer)
+			}
+			handler := NewHandler(newTestOrigin("Cache-Control", "max-age=60", "Vary", "Accept-Encoding"),
+				WithStorer(storer),
+				WithKeyer(keyer),
+				WithPurge(func(r *http.Request) bool { return true }),
+			)
+			do := func(method, path, encoding string) *http.Response {
+				req := httptest.NewRequest(method, "http://example.com"+path, nil)
+				if encoding != "" {
+					req.Header.Set("Accept-Encoding", encoding)
+				}
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, req)
+				return rw.Result()
+			}
+			for _, encoding := range []string{"gzip", "br"} {
+				do(http.MethodGet, "/a", encoding)
+			}
+			do(http.MethodGet, "/b", "gzip")
+
+			resp := do(MethodPurge, "/a", "")
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Httpcache-Deleted") != "2" {
+				t.Fatalf("unexpected %d %v", resp.StatusCode, resp.Header)
+			}
+			for _, encoding := range []string{"gzip", "br"} {
+				if got := readBodyString(t, do(http.MethodGet, "/a", encoding)); got == "1" || got == "2" {
+					t.Errorf("%s: want a miss after the purge, got %q", encoding, got)
+				}
+			}
+			if got := readBodyString(t, do(http.MethodGet, "/b", "gzip")); got != "1" {
+				t.Errorf("want the other entry to be kept, got %q", got)
+			}
+		})
+	}
+}
@@ -1,10 +1,9 @@
 package httpcache
 
 import (
-	"bytes"
-	"io"
 	"net/http"
 	"sync"
+	"time"
 )
 
 type RoundTripper struct {
@@ -20,42 +19,55 @@ func NewRoundTripper(base http.RoundTripper, options ...Option) http.RoundTrippe
 	crt := &RoundTripper{
 		RoundTripper: base,
 	}
-	crt.option.init(options)
+	crt.option.init(PrivateMode, options)
 	return crt
 }
 
 func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if !r.filterer.Filter(req) {
+	key, ok := r.key(req)
+	if !ok {
 		return r.RoundTripper.RoundTrip(req)
 	}
-	key := r.keyer.Key(req)
 
-	data, ok := r.storer.Get(key)
-	if ok {
-		resp, err := unmarshalResponse(data)
-		if err == nil {
-			data.Close()
+	directives := parseRequestDirectives(req.Header)
+	result := lookupRejected
+	// With only-if-cached, the origin cannot be asked to revalidate the entry,
+	// so no-cache is ignored in favor of serving the entry rather than always failing.
+	if !directives.noCache || directives.onlyIfCached {
+		var resp *http.Response
+		resp, result = r.load(req, key, directives.accept)
+		if resp != nil {
+			r.hit(req, key, resp, result)
 			return resp, nil
 		}
-		data.Close()
+	}
+	if directives.onlyIfCached {
+		return gatewayTimeout(req), nil
 	}
 
 	var mutex sync.RWMutex
 	mut, ok := r.muts.LoadOrStore(key, &mutex)
 	rmut := mut.(*sync.RWMutex)
 	if ok {
-		rmut.RLock()
+		r.wait(req, key, rmut)
 		defer rmut.RUnlock()
-		data, ok := r.storer.Get(key)
-		if ok {
-			resp, err := unmarshalResponse(data)
-			if err == nil {
-				data.Close()
+		result := lookupRejected
+		// The fill may have been discarded, leaving the entry no-cache rejected in place.
+		if !directives.noCache {
+			var resp *http.Response
+			resp, result = r.load(req, key, directives.accept)
+			if resp != nil {
+				r.hit(req, key, resp, result)
 				return resp, nil
 			}
-			data.Close()
 		}
-		return r.RoundTripper.RoundTrip(req)
+		fetchReq, span := r.fetch(req, key, result)
+		defer span.End()
+		resp, err := r.RoundTripper.RoundTrip(fetchReq)
+		if err != nil {
+			span.RecordError(err)
+		}
+		return resp, err
 	}
 
 	rmut.Lock()
@@ -64,31 +76,33 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		r.muts.Delete(key)
 	}()
 
-	resp, err := r.RoundTripper.RoundTrip(req)
+	fetchReq, span := r.fetch(req, key, result)
+	start := time.Now()
+	resp, err := r.RoundTripper.RoundTrip(fetchReq)
 	if err != nil {
+		span.RecordError(err)
+		span.End()
 		return resp, err
 	}
+	span.SetAttribute(AttributeStatusCode, int64(resp.StatusCode))
 
-	if r.discarder.Discard(response{resp}) {
-		return resp, err
+	if !r.admitHeader(req, response{resp}) {
+		span.End()
+		r.observe(req, Event{Kind: EventDiscard, Key: key})
+		return resp, nil
 	}
 
 	buffer := getBuffer()
 	_, err = buffer.ReadFrom(resp.Body)
 	if err != nil {
+		span.RecordError(err)
+		span.End()
 		putBuffer(buffer)
 		return resp, err
 	}
+	span.SetAttribute(AttributeBytes, int64(buffer.Len()))
+	span.End()
 	resp.Body.Close()
-
-	if buf, ok := r.storer.Put(key); ok {
-		resp.Body = io.NopCloser(bytes.NewBuffer(buffer.Bytes()))
-		err = marshalResponse(resp, buf)
-		buf.Close()
-		if err != nil {
-			r.storer.Del(key)
-		}
-	}
 	resp.Body = &readerWithClose{
 		Reader: buffer,
 		close: func() error {
@@ -96,9 +110,30 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 			return nil
 		},
 	}
+
+	storeKey, meta, ok := r.admit(req, response{resp}, buffer.Bytes(), key)
+	if !ok {
+		r.observe(req, Event{Kind: EventDiscard, Key: key})
+		return resp, nil
+	}
+	r.store(req, storeKey, resp, buffer.Bytes(), meta, start)
 	return resp, nil
 }
 
+// gatewayTimeout is the response to only-if-cached requests that cannot be answered from the cache.
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
 type response struct {
 	*http.Response
 }
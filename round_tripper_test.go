@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestRoundTripper(t *testing.T) {
@@ -67,21 +70,26 @@ func TestParallelRoundTripper(t *testing.T) {
 			defer wg.Done()
 			resp, err := cli.Get(server.URL + "/transport")
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			body, err := io.ReadAll(resp.Body)
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			resp.Body.Close()
 			if resp.StatusCode != http.StatusAccepted {
-				t.Fatalf("want %q, got %q", http.StatusAccepted, resp.StatusCode)
+				t.Errorf("want %q, got %q", http.StatusAccepted, resp.StatusCode)
+				return
 			}
 			if got := resp.Header.Get("want"); !reflect.DeepEqual(want, got) {
-				t.Fatalf("want %q, got %q", want, got)
+				t.Errorf("want %q, got %q", want, got)
+				return
 			}
 			if !reflect.DeepEqual(want, string(body)) {
-				t.Fatalf("want %q, got %q", want, body)
+				t.Errorf("want %q, got %q", want, body)
+				return
 			}
 		}()
 	}
@@ -92,6 +100,152 @@ func TestParallelRoundTripper(t *testing.T) {
 	}
 }
 
+func TestBodyDiscarderRoundTripper(t *testing.T) {
+	var count int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		rw.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/error" || n == 1 {
+			rw.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		rw.Write([]byte(`{"data":"OK"}`))
+	}))
+	cli := server.Client()
+	cli.Transport = NewRoundTripper(cli.Transport,
+		WithDiscarder(OrJointDiscarder(NormalDiscarder(), JSONPathDiscarder(1024, "error"))),
+	)
+
+	for i, want := range []string{`{"error":"unavailable"}`, `{"data":"OK"}`, `{"data":"OK"}`} {
+		resp, err := cli.Get(server.URL + "/transport")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if string(body) != want {
+			t.Fatalf("request %d: want %q, got %q", i, want, body)
+		}
+	}
+
+	if count != 2 {
+		t.Fatalf("want 2 origin requests, got %d", count)
+	}
+}
+
+func TestRequestDirectivesRoundTripper(t *testing.T) {
+	clock := newTestClock()
+	origin := newTestOrigin("Cache-Control", "max-age=60")
+	transport := NewRoundTripper(origin, withClock(clock))
+	get := func(path, cacheControl string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	steps := []struct {
+		advance      time.Duration
+		cacheControl string
+		want         string
+	}{
+		{0, "", "1"},
+		{30 * time.Second, "", "1"},
+		{0, "max-age=40", "1"},
+		{0, "max-age=20", "2"},
+		{0, "", "2"},
+		{40 * time.Second, "min-fresh=30", "3"},
+		{60 * time.Second, "", "4"},
+		{70 * time.Second, "max-stale=5", "5"},
+		{70 * time.Second, "max-stale=15", "5"},
+		{0, "max-stale", "5"},
+		{0, "no-cache", "6"},
+		{0, "", "6"},
+	}
+	for i, step := range steps {
+		clock.Add(step.advance)
+		if got := readBodyString(t, get("/directives", step.cacheControl)); got != step.want {
+			t.Fatalf("step %d %q: want %q, got %q", i, step.cacheControl, step.want, got)
+		}
+	}
+
+	resp := get("/missing", "only-if-cached")
+	readBodyString(t, resp)
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("want %d, got %d", http.StatusGatewayTimeout, resp.StatusCode)
+	}
+	if origin.counts["/missing"] != 0 {
+		t.Fatalf("want no origin request, got %d", origin.counts["/missing"])
+	}
+	resp = get("/directives", "only-if-cached")
+	if got := readBodyString(t, resp); got != "6" {
+		t.Fatalf("want %q, got %q", "6", got)
+	}
+	resp = get("/directives", "no-cache, only-if-cached")
+	if got := readBodyString(t, resp); got != "6" {
+		t.Fatalf("want %q, got %q", "6", got)
+	}
+}
+
+func TestNoCacheCoalescedRoundTripper(t *testing.T) {
+	var count int64
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	origin := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		n := atomic.AddInt64(&count, 1)
+		rw := httptest.NewRecorder()
+		rw.Header().Set("Cache-Control", "max-age=60")
+		if n == 2 {
+			// The fill of the first no-cache request is discarded.
+			rw.Header().Set("Cache-Control", "no-store")
+			started <- struct{}{}
+			<-release
+		}
+		rw.Write([]byte(strconv.FormatInt(n, 10)))
+		return rw.Result(), nil
+	})
+	transport := NewRoundTripper(origin)
+	get := func(cacheControl string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("Cache-Control", cacheControl)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+	if got := get(""); got != "1" {
+		t.Fatalf("want %q, got %q", "1", got)
+	}
+
+	done := make(chan string)
+	go func() {
+		done <- get("no-cache")
+	}()
+	<-started
+	go func() {
+		done <- get("no-cache")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	got := []string{<-done, <-done}
+	sort.Strings(got)
+	if want := []string{"2", "3"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
 func BenchmarkCacheMemoryRoundTripper(b *testing.B) {
 	want := "OK"
 	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
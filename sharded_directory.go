@@ -0,0 +1,277 @@
+package httpcache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ShardedDirectory is a Storer that keeps every entry in a file named after the hash of its key,
+// spread over nested directories named after the leading characters of the hash, e.g. ab/cd/abcd...
+// The key is written in the first line of each file so that hash collisions are detected.
+type ShardedDirectory struct {
+	dir    string
+	levels int
+	width  int
+	hash   func() hash.Hash
+}
+
+type ShardedDirectoryOption func(d *ShardedDirectory)
+
+// WithShardFanOut sets the number of nested directories and the number of hex characters naming each,
+// a width of 2 gives a fan-out of 256 per level.
+func WithShardFanOut(levels, width int) ShardedDirectoryOption {
+	return func(d *ShardedDirectory) {
+		d.levels = levels
+		d.width = width
+	}
+}
+
+// WithShardHash sets the hash of keys, the default is SHA-256.
+func WithShardHash(hash func() hash.Hash) ShardedDirectoryOption {
+	return func(d *ShardedDirectory) {
+		d.hash = hash
+	}
+}
+
+func ShardedDirectoryStorer(dir string, options ...ShardedDirectoryOption) Storer {
+	d := &ShardedDirectory{
+		dir:    dir,
+		levels: 2,
+		width:  2,
+		hash:   sha256.New,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.levels < 0 {
+		d.levels = 0
+	}
+	if d.width < 1 {
+		d.width = 1
+	}
+	if size := d.hash().Size() * 2; d.levels*d.width > size {
+		d.levels = size / d.width
+	}
+	return d
+}
+
+func (d *ShardedDirectory) path(key string) string {
+	h := d.hash()
+	h.Write([]byte(key))
+	name := hex.EncodeToString(h.Sum(nil))
+	elem := make([]string, 0, d.levels+2)
+	elem = append(elem, d.dir)
+	for i := 0; i != d.levels; i++ {
+		elem = append(elem, name[i*d.width:(i+1)*d.width])
+	}
+	elem = append(elem, name)
+	return filepath.Join(elem...)
+}
+
+func (d *ShardedDirectory) Get(key string) (io.ReadCloser, bool) {
+	f, err := os.OpenFile(d.path(key), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, false
+	}
+	br := getReader(f)
+	got, err := readShardedKey(br)
+	if err != nil || got != key {
+		putReader(br)
+		f.Close()
+		return nil, false
+	}
+	return &readerWithClose{
+		Reader: br,
+		close: func() error {
+			putReader(br)
+			return f.Close()
+		},
+	}, true
+}
+
+func (d *ShardedDirectory) Put(key string) (io.WriteCloser, bool) {
+	path := d.path(key)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	w, err := writeToCompletion(path, 0644)
+	if err != nil {
+		return nil, false
+	}
+	_, err = io.WriteString(w, strconv.Quote(key)+"\n")
+	if err != nil {
+		w.Close()
+		os.Remove(path)
+		return nil, false
+	}
+	return w, true
+}
+
+func (d *ShardedDirectory) Del(key string) bool {
+	return os.Remove(d.path(key)) == nil
+}
+
+// List reads the key of every file, as the files are not named after the keys,
+// a page only keeps the keys that may be in it rather than sorting all of them.
+func (d *ShardedDirectory) List(prefix, after string, limit int, fn func(key string, size int64) error) error {
+	var page *listPage
+	if limit > 0 {
+		page = newListPage(limit)
+	}
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		key, size, ok := statShardedEntry(path)
+		if !ok || key <= after || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if page != nil {
+			page.add(key, size)
+			return nil
+		}
+		return fn(key, size)
+	})
+	if err != nil || page == nil {
+		return err
+	}
+	return page.each(fn)
+}
+
+func (d *ShardedDirectory) Stat(key string) (EntryInfo, bool) {
+	path := d.path(key)
+	got, size, ok := statShardedEntry(path)
+	if !ok || got != key {
+		return EntryInfo{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return EntryInfo{}, false
+	}
+	return EntryInfo{
+		Size:    size,
+		ModTime: info.ModTime(),
+		Metadata: map[string]string{
+			"path": path,
+		},
+	}, true
+}
+
+// statShardedEntry returns the key and the size of the data of the entry.
+func statShardedEntry(path string) (string, int64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, false
+	}
+	br := getReader(f)
+	defer putReader(br)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", 0, false
+	}
+	key, err := strconv.Unquote(strings.TrimSuffix(line, "\n"))
+	if err != nil {
+		return "", 0, false
+	}
+	return key, info.Size() - int64(len(line)), true
+}
+
+func readShardedKey(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strconv.Unquote(strings.TrimSuffix(line, "\n"))
+}
+
+// MigrateDirectory moves every entry of the flat Directory layout into the storer,
+// which is typically a ShardedDirectory outside of the directory.
+// Entries are read by their file path, so that keys the Directory refuses today are still moved,
+// and removed from the directory once they are stored.
+// Entries that cannot be moved are left in place and reported in the returned error
+// without stopping the migration.
+func MigrateDirectory(from Directory, to Storer) error {
+	root := string(from)
+	var errs []error
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || isTemporaryFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		err = migrateEntry(path, to, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to migrate %q: %w", key, err))
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// isTemporaryFile reports whether the file is one being written by writeToCompletion.
+func isTemporaryFile(path string) bool {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmp")
+	if len(name) == len(filepath.Base(path)) {
+		return false
+	}
+	i := strings.LastIndexByte(name, '.')
+	if i == -1 {
+		return false
+	}
+	_, err := strconv.ParseUint(name[i+1:], 10, 64)
+	return err == nil
+}
+
+func migrateEntry(path string, to Storer, key string) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, ok := to.Put(key)
+	if !ok {
+		return fmt.Errorf("entry is refused")
+	}
+	buf := getBytes()
+	_, err = io.CopyBuffer(w, r, buf)
+	putBytes(buf)
+	if err != nil {
+		w.Close()
+		to.Del(key)
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		to.Del(key)
+		return err
+	}
+	return os.Remove(path)
+}
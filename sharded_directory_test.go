@@ -0,0 +1,144 @@
+package httpcache
+
+import (
+	"crypto/md5"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShardedDirectoryLayout(t *testing.T) {
+	root := t.TempDir()
+	d := ShardedDirectoryStorer(root, WithShardFanOut(3, 1), WithShardHash(md5.New))
+	w, ok := d.Put("host/a/b")
+	if !ok {
+		t.Fatal("expected to get the writer")
+	}
+	w.Write([]byte("OK"))
+	w.Close()
+
+	// md5("host/a/b") = 1fcbdbe6e0dd10a02d6c7efe6776984a
+	want := filepath.Join(root, "1", "f", "c", "1fcbdbe6e0dd10a02d6c7efe6776984a")
+	if _, err := os.Stat(want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateDirectory(t *testing.T) {
+	from := Directory(filepath.Join(t.TempDir(), "flat"))
+	to := ShardedDirectoryStorer(filepath.Join(t.TempDir(), "sharded"))
+	entries := map[string]string{
+		"example.com/Lw":      "root",
+		"example.com/L2EvYg":  "nested",
+		"example.org:8080/Lw": "port",
+	}
+	for key, data := range entries {
+		w, ok := from.Put(key)
+		if !ok {
+			t.Fatalf("expected to get the writer for %q", key)
+		}
+		w.Write([]byte(data))
+		w.Close()
+	}
+
+	err := MigrateDirectory(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range entries {
+		if _, ok := from.Get(key); ok {
+			t.Errorf("expected %q to be moved", key)
+		}
+		r, ok := to.Get(key)
+		if !ok {
+			t.Errorf("expected %q to be available", key)
+			continue
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+	if _, err := os.Stat(string(from)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateDirectoryLegacyNames(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "flat")
+	to := ShardedDirectoryStorer(filepath.Join(t.TempDir(), "sharded"))
+	entries := map[string]string{
+		"example.com/" + strings.Repeat("a", 220): "long",
+		`example.com/back\slash`:                  "backslash",
+		"example.com/file.tmp":                    "suffix",
+	}
+	for key, data := range entries {
+		path := filepath.Join(root, filepath.FromSlash(key))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	partial := filepath.Join(root, "example.com", "Lw.123.tmp")
+	if err := os.WriteFile(partial, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := MigrateDirectory(Directory(root), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range entries {
+		r, ok := to.Get(key)
+		if !ok {
+			t.Errorf("expected %q to be available", key)
+			continue
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+	if _, err := os.Stat(partial); err != nil {
+		t.Errorf("expected the file being written to be left in place: %s", err)
+	}
+}
+
+func TestMigrateDirectoryReportsFailures(t *testing.T) {
+	from := Directory(filepath.Join(t.TempDir(), "flat"))
+	for _, key := range []string{"a/1", "b/2"} {
+		w, _ := from.Put(key)
+		w.Write([]byte(key))
+		w.Close()
+	}
+	to := refusingKeyStorer{Storer: MemoryStorer(), refused: "a/1"}
+
+	err := MigrateDirectory(from, to)
+	if err == nil || !strings.Contains(err.Error(), `"a/1"`) {
+		t.Fatalf("expected the failure of a/1 to be reported, got %v", err)
+	}
+	if _, ok := to.Get("b/2"); !ok {
+		t.Error("expected b/2 to be moved despite the failure of a/1")
+	}
+	if _, ok := from.Get("a/1"); !ok {
+		t.Error("expected a/1 to be left in place")
+	}
+}
+
+// refusingKeyStorer refuses to store one key.
+type refusingKeyStorer struct {
+	Storer
+	refused string
+}
+
+func (s refusingKeyStorer) Put(key string) (io.WriteCloser, bool) {
+	if key == s.refused {
+		return nil, false
+	}
+	return s.Storer.Put(key)
+}
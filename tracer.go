@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Tracer starts the spans of the cache operations, such as an adapter of an OpenTelemetry tracer.
+// Spans are named httpcache.key, httpcache.lookup, httpcache.wait, httpcache.fetch and httpcache.store.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation started by the Tracer.
+type Span interface {
+	// SetAttribute sets an attribute, the value is a string or an int64.
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Attributes set on the spans.
+const (
+	AttributeKey        = "httpcache.key"
+	AttributeOutcome    = "httpcache.outcome"
+	AttributeBytes      = "httpcache.bytes"
+	AttributeStatusCode = "http.status_code"
+)
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+func (o *option) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if o.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return o.tracer.Start(ctx, name)
+}
+
+// key returns the key of the request, or false if the request bypasses the cache.
+func (o *option) key(req *http.Request) (string, bool) {
+	_, span := o.startSpan(req.Context(), "httpcache.key")
+	defer span.End()
+	if !o.filterer.Filter(req) {
+		span.SetAttribute(AttributeOutcome, EventBypass.String())
+		o.observe(req, Event{Kind: EventBypass})
+		return "", false
+	}
+	key, ok := keyRequest(o.keyer, req)
+	if !ok {
+		span.SetAttribute(AttributeOutcome, EventBypass.String())
+		o.observe(req, Event{Kind: EventBypass})
+		return "", false
+	}
+	span.SetAttribute(AttributeKey, key)
+	return key, true
+}
+
+// wait blocks until the request filling the key is done,
+// the caller must release the read lock.
+func (o *option) wait(req *http.Request, key string, mut *sync.RWMutex) {
+	_, span := o.startSpan(req.Context(), "httpcache.wait")
+	defer span.End()
+	span.SetAttribute(AttributeKey, key)
+	start := time.Now()
+	mut.RLock()
+	o.observe(req, Event{Kind: EventCoalesced, Key: key, Duration: time.Since(start)})
+}
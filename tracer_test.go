@@ -0,0 +1,107 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+// testTracer records the ended spans, named after their parent and their own name.
+type testTracer struct {
+	mut   sync.Mutex
+	spans []*testSpan
+}
+
+type testSpan struct {
+	tracer *testTracer
+	name   string
+	attrs  map[string]interface{}
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	if parent, ok := ctx.Value(testSpanKey{}).(*testSpan); ok {
+		name = parent.name + "/" + name
+	}
+	span := &testSpan{tracer: t, name: name, attrs: map[string]interface{}{}}
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (t *testTracer) names() []string {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	names := []string{}
+	for _, span := range t.spans {
+		names = append(names, span.name)
+	}
+	t.spans = nil
+	return names
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.attrs["error"] = err.Error()
+}
+
+func (s *testSpan) End() {
+	s.tracer.mut.Lock()
+	defer s.tracer.mut.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+func TestTracer(t *testing.T) {
+	for _, name := range []string{"Handler", "RoundTripper"} {
+		t.Run(name, func(t *testing.T) {
+			tracer := &testTracer{}
+			origin := newTestOrigin()
+			caches := testCaches(func() *testOrigin { return origin }, WithTracer(tracer))
+			do := caches[name]
+
+			readBodyString(t, do(httptest.NewRequest(http.MethodGet, "/200", nil)))
+			want := []string{"httpcache.key", "httpcache.lookup", "httpcache.fetch", "httpcache.store"}
+			if got := tracer.names(); !reflect.DeepEqual(got, want) {
+				t.Errorf("want %q, got %q", want, got)
+			}
+
+			readBodyString(t, do(httptest.NewRequest(http.MethodGet, "/200", nil)))
+			want = []string{"httpcache.key", "httpcache.lookup"}
+			tracer.mut.Lock()
+			lookup := tracer.spans[1]
+			tracer.mut.Unlock()
+			if got := tracer.names(); !reflect.DeepEqual(got, want) {
+				t.Errorf("want %q, got %q", want, got)
+			}
+			if lookup.attrs[AttributeOutcome] != "hit" || lookup.attrs[AttributeBytes] != int64(1) {
+				t.Errorf("unexpected attributes %v", lookup.attrs)
+			}
+
+			readBodyString(t, do(httptest.NewRequest(http.MethodPost, "/200", nil)))
+			want = []string{"httpcache.key"}
+			if got := tracer.names(); !reflect.DeepEqual(got, want) {
+				t.Errorf("want %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestTracerFetchContext(t *testing.T) {
+	tracer := &testTracer{}
+	var got string
+	origin := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if span, ok := r.Context().Value(testSpanKey{}).(*testSpan); ok {
+			got = span.name
+		}
+	})
+	handler := NewHandler(origin, WithTracer(tracer))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got != "httpcache.fetch" {
+		t.Errorf("want the origin to be called in the fetch span, got %q", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -157,3 +158,58 @@ func (a *autoCloser) Read(p []byte) (int, error) {
 	}
 	return n, err
 }
+
+// listPage keeps the page of the keys given to add, the first limit keys in lexical order,
+// without sorting all of them.
+type listPage struct {
+	limit   int
+	entries []listEntry
+	// last is the last key of a full page once trimmed, later keys cannot be in the page.
+	full bool
+	last string
+}
+
+type listEntry struct {
+	key  string
+	size int64
+}
+
+func newListPage(limit int) *listPage {
+	return &listPage{
+		limit:   limit,
+		entries: make([]listEntry, 0, 2*limit),
+	}
+}
+
+func (p *listPage) add(key string, size int64) {
+	if p.full && key >= p.last {
+		return
+	}
+	p.entries = append(p.entries, listEntry{key: key, size: size})
+	if len(p.entries) == 2*p.limit {
+		p.trim()
+	}
+}
+
+// trim sorts the entries and drops those past the limit.
+func (p *listPage) trim() {
+	sort.Slice(p.entries, func(i, j int) bool {
+		return p.entries[i].key < p.entries[j].key
+	})
+	if len(p.entries) >= p.limit {
+		p.entries = p.entries[:p.limit]
+		p.full = true
+		p.last = p.entries[p.limit-1].key
+	}
+}
+
+// each calls fn with the keys of the page in lexical order.
+func (p *listPage) each(fn func(key string, size int64) error) error {
+	p.trim()
+	for _, entry := range p.entries {
+		if err := fn(entry.key, entry.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}